@@ -0,0 +1,238 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirnewton01/gdblib"
+	"strconv"
+	"strings"
+)
+
+// Gdb settings that are carried in a bookmark since they change how the
+// variables look
+var bookmarkSettings = []string{"print elements", "print pretty", "print address",
+	"print symbol"}
+
+// Where the program was stopped along with what was being looked at. It is
+// encoded into a token that can be shared with others debugging the same
+// program.
+type bookmark struct {
+	// Innermost frame of the selected thread where the program stopped
+	Func string
+	File string
+	Line string
+
+	Thread   string
+	Frame    int
+	Displays []string
+	Settings map[string]string
+}
+
+var errBookmarkLocation = errors.New("The program isn't stopped where the bookmark was taken")
+
+var errBookmarkInvalid = errors.New("Not a valid bookmark")
+
+// Checks the parts of a bookmark that are handed to gdb, each one has to
+// stay a single command
+func checkBookmark(b *bookmark) error {
+	if b.Thread != "" {
+		if _, err := strconv.Atoi(b.Thread); err != nil {
+			return fmt.Errorf("%v: thread %v", errBookmarkInvalid, b.Thread)
+		}
+	}
+
+	for setting, value := range b.Settings {
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("%v: %v is not a single line", errBookmarkInvalid, setting)
+		}
+	}
+
+	for _, expression := range b.Displays {
+		if strings.ContainsAny(expression, "\r\n") {
+			return fmt.Errorf("%v: %v is not a single line", errBookmarkInvalid, expression)
+		}
+	}
+
+	return nil
+}
+
+func encodeBookmark(b *bookmark) (string, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(data), nil
+}
+
+func decodeBookmark(token string) (*bookmark, error) {
+	data, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &bookmark{}
+	err = json.Unmarshal(data, b)
+	return b, err
+}
+
+func innermostFrame(mygdb *tracedGDB) (*backtraceFrame, error) {
+	stackResult, err := mygdb.StackListFrames(gdblib.StackListFramesParms{})
+	if err != nil {
+		return nil, err
+	}
+
+	stack := struct {
+		Stack []backtraceFrame `json:"stack"`
+	}{}
+
+	err = remarshal(stackResult, &stack)
+	if err != nil {
+		return nil, err
+	}
+	if len(stack.Stack) == 0 {
+		return nil, errors.New("No frames")
+	}
+
+	return &stack.Stack[0], nil
+}
+
+func takeBookmark(mygdb *tracedGDB) (*bookmark, error) {
+	b := &bookmark{Displays: []string{}, Settings: make(map[string]string)}
+
+	idsResult, err := mygdb.ThreadListIds()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := struct {
+		CurrentThreadId string `json:"current-thread-id"`
+	}{}
+
+	err = remarshal(idsResult, &ids)
+	if err != nil {
+		return nil, err
+	}
+	b.Thread = ids.CurrentThreadId
+
+	frameResult, err := mygdb.StackInfoFrame()
+	if err != nil {
+		return nil, err
+	}
+
+	frame := struct {
+		Frame struct {
+			Level string `json:"level"`
+		} `json:"frame"`
+	}{}
+
+	err = remarshal(frameResult, &frame)
+	if err != nil {
+		return nil, err
+	}
+	b.Frame, _ = strconv.Atoi(frame.Frame.Level)
+
+	innermost, err := innermostFrame(mygdb)
+	if err != nil {
+		return nil, err
+	}
+	b.Func, b.File, b.Line = innermost.Func, innermost.File, innermost.Line
+
+	cliOutput, err := cliExec(mygdb, "info display")
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(cliOutput, "\n") {
+		match := displayInfoPattern.FindStringSubmatch(line)
+		if match != nil {
+			b.Displays = append(b.Displays, strings.TrimSpace(match[3]))
+		}
+	}
+
+	for _, setting := range bookmarkSettings {
+		result, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: setting})
+		if err == nil {
+			b.Settings[setting] = result.Value
+		}
+	}
+
+	return b, nil
+}
+
+// Selects the thread and frame of the bookmark, adds any of its displays
+// that are missing and applies its settings. The program must be stopped
+// at the same place that it was when the bookmark was taken. A token can
+// come from anyone (e.g. a link) so only the bookmark settings are applied
+// and the displays, which gdb evaluates, are only added with -allow-write.
+// The displays that weren't added are returned.
+func loadBookmark(mygdb *tracedGDB, b *bookmark) ([]string, error) {
+	if err := checkBookmark(b); err != nil {
+		return nil, err
+	}
+
+	if b.Thread != "" {
+		_, err := mygdb.ThreadSelect(gdblib.ThreadSelectParms{ThreadId: b.Thread})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	innermost, err := innermostFrame(mygdb)
+	if err != nil {
+		return nil, err
+	}
+	if innermost.Func != b.Func || innermost.File != b.File || innermost.Line != b.Line {
+		return nil, fmt.Errorf("%v (%v at %v:%v)", errBookmarkLocation, b.Func, b.File, b.Line)
+	}
+
+	_, err = cliExec(mygdb, "frame "+strconv.Itoa(b.Frame))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, setting := range bookmarkSettings {
+		value, ok := b.Settings[setting]
+		if !ok {
+			continue
+		}
+
+		err = mygdb.GdbSet(gdblib.GdbSetParms{Variable: setting, Value: value})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !*allowWrite {
+		return b.Displays, nil
+	}
+
+	cliOutput, err := cliExec(mygdb, "info display")
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]bool)
+	for _, line := range strings.Split(cliOutput, "\n") {
+		match := displayInfoPattern.FindStringSubmatch(line)
+		if match != nil {
+			existing[strings.TrimSpace(match[3])] = true
+		}
+	}
+
+	for _, expression := range b.Displays {
+		if !existing[expression] {
+			_, err = cliExec(mygdb, "display "+expression)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return []string{}, nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Sections holding the build id note, the Go one is preferred since the
+// linker always writes it
+var buildIdSections = []string{".note.go.buildid", ".note.gnu.build-id"}
+
+// Matches the address range of a build id section of the executable in the
+// info files output, the sections of shared libraries end with "in <file>"
+// (e.g. "0x0000000000400f9c - 0x0000000000400fc0 is .note.go.buildid")
+var buildIdSectionPattern = regexp.MustCompile(`(?m)^\s*(0x[0-9a-fA-F]+) - (0x[0-9a-fA-F]+) is (\.note\.go\.buildid|\.note\.gnu\.build-id)\s*$`)
+
+// Matches a build setting in the go version -m output
+// (e.g. "	build	vcs.revision=0123abcd")
+var buildSettingPattern = regexp.MustCompile(`(?m)^\s+build\s+([^=\s]+)=(.*)$`)
+
+var errNoBuildId = errors.New("No build id")
+
+// Pulls the build id out of the contents of a note section. The GNU build id
+// is binary so it is shown in hex while the Go one is already text.
+func parseBuildIdNote(section string, note []byte, order binary.ByteOrder) (string, error) {
+	if len(note) < 12 {
+		return "", errNoBuildId
+	}
+
+	nameSize := int(order.Uint32(note[0:4]))
+	descSize := int(order.Uint32(note[4:8]))
+	descStart := 12 + (nameSize+3)&^3
+
+	if descStart+descSize > len(note) {
+		return "", errNoBuildId
+	}
+
+	desc := note[descStart : descStart+descSize]
+	if section == ".note.gnu.build-id" {
+		return hex.EncodeToString(desc), nil
+	}
+	return string(desc), nil
+}
+
+// Reads the build id of the executable file as it is now on disk along with
+// the section that it is in and the byte order of the file
+func fileBuildId(path string) (string, string, binary.ByteOrder, error) {
+	file, err := elf.Open(path)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("%v: %v", errUnsupportedPlatform, err)
+	}
+	defer file.Close()
+
+	for _, name := range buildIdSections {
+		section := file.Section(name)
+		if section == nil {
+			continue
+		}
+
+		note, err := section.Data()
+		if err != nil {
+			return "", "", nil, err
+		}
+
+		id, err := parseBuildIdNote(name, note, file.ByteOrder)
+		return name, id, file.ByteOrder, err
+	}
+
+	return "", "", nil, errNoBuildId
+}
+
+// Reads the build id from the executable that gdb loaded, which comes from
+// the memory of the program once it is running
+func loadedBuildId(mygdb *tracedGDB, section string, order binary.ByteOrder) (string, error) {
+	cliOutput, err := cliExec(mygdb, "info files")
+	if err != nil {
+		return "", err
+	}
+
+	for _, match := range buildIdSectionPattern.FindAllStringSubmatch(cliOutput, -1) {
+		if match[3] != section {
+			continue
+		}
+
+		start, ok := parseAddress(match[1])
+		end, endOk := parseAddress(match[2])
+		if !ok || !endOk || end <= start {
+			return "", errNoBuildId
+		}
+
+		_, note, err := readMemory(mygdb, match[1], int(end-start))
+		if err != nil {
+			return "", err
+		}
+
+		return parseBuildIdNote(section, note, order)
+	}
+
+	return "", errNoBuildId
+}
+
+type buildIdCheck struct {
+	Section  string
+	BuildId  string
+	LoadedId string
+	// Version control revision that the executable was built from and
+	//  whether there were uncommitted changes, only Go 1.18 and later
+	//  record it.
+	Revision       string `json:",omitempty"`
+	Modified       bool
+	SourceRevision string `json:",omitempty"`
+	Mismatch       bool
+	Warnings       []string
+}
+
+// Compares the build id of the executable that gdb loaded with the one on
+// disk and the revision it was built from with the revision of the source.
+func checkBuildId(mygdb *tracedGDB, path string, srcDir string) (*buildIdCheck, error) {
+	check := &buildIdCheck{Warnings: []string{}}
+
+	section, id, order, err := fileBuildId(path)
+	if err != nil {
+		return nil, err
+	}
+	check.Section = section
+	check.BuildId = id
+
+	check.LoadedId, err = loadedBuildId(mygdb, section, order)
+	if err != nil {
+		return nil, err
+	}
+
+	if check.LoadedId != check.BuildId {
+		check.Mismatch = true
+		check.Warnings = append(check.Warnings, "The executable has been rebuilt since gdb loaded it")
+	}
+
+	// The build settings are read by the go tool so that godbg itself
+	//  still builds with releases older than Go 1.18.
+	buildInfo, err := exec.Command("go", "version", "-m", path).Output()
+	if err == nil {
+		for _, match := range buildSettingPattern.FindAllStringSubmatch(string(buildInfo), -1) {
+			switch match[1] {
+			case "vcs.revision":
+				check.Revision = strings.TrimSpace(match[2])
+			case "vcs.modified":
+				check.Modified = strings.TrimSpace(match[2]) == "true"
+			}
+		}
+	}
+
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = srcDir
+	revision, err := cmd.Output()
+	if err == nil {
+		check.SourceRevision = strings.TrimSpace(string(revision))
+	}
+
+	if check.Revision != "" && check.SourceRevision != "" && check.Revision != check.SourceRevision {
+		check.Mismatch = true
+		check.Warnings = append(check.Warnings, "The executable was built from revision "+check.Revision+
+			" but the source is at revision "+check.SourceRevision)
+	}
+
+	if check.Modified {
+		check.Warnings = append(check.Warnings, "The executable was built with uncommitted changes")
+	}
+
+	return check, nil
+}
@@ -5,19 +5,73 @@
 /*global window define document*/
 /*browser:true*/
 
-define(['orion/xhr'], function(xhr) {
+define(['orion/xhr', 'text!handle/gdb/config'], function(xhr, configText) {
+	// Server generated configuration (e.g. the path prefix for all of the urls)
+	var config = JSON.parse(configText);
+	var basePath = config.BasePath;
+	
+	// A bookmark from the url is loaded the first time that the program stops
+	var bookmarkMatch = /[?&]bookmark=([^&]*)/.exec(window.location.search);
+	var pendingBookmark = bookmarkMatch ? decodeURIComponent(bookmarkMatch[1]) : null;
+
 	// Handle xhr errors in a uniform way
 	var handleXhrError = function(e) {
 		window.alert("ERROR: "+e.responseText);
 	};
 
+	// Colors of the ANSI foreground color codes 30-37 (and 90-97 for the bright ones)
+	var ansiColors = ["black", "red", "green", "olive", "blue", "purple", "teal", "silver"];
+	var ansiBrightColors = ["gray", "#ff5555", "lime", "yellow", "#5555ff", "fuchsia", "aqua", "white"];
+	
+	// Converts program output with ANSI color escapes into HTML spans
+	var ansiToHtml = function(text) {
+		var html = "";
+		var open = false;
+		var parts = text.split(/\x1b\[([0-9;]*)m/);
+		
+		for (var i = 0; i < parts.length; i++) {
+			if (i % 2 === 0) {
+				html = html + parts[i].replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;");
+				continue;
+			}
+			
+			var style = "";
+			var codes = parts[i].split(";");
+			for (var j = 0; j < codes.length; j++) {
+				var code = parseInt(codes[j] || "0", 10);
+				
+				if (code === 1) {
+					style = style + "font-weight: bold;";
+				} else if (code >= 30 && code <= 37) {
+					style = style + "color: " + ansiColors[code - 30] + ";";
+				} else if (code >= 90 && code <= 97) {
+					style = style + "color: " + ansiBrightColors[code - 90] + ";";
+				}
+			}
+			
+			if (open) {
+				html = html + "</span>";
+				open = false;
+			}
+			if (style !== "") {
+				html = html + "<span style=\"" + style + "\">";
+				open = true;
+			}
+		}
+		
+		if (open) {
+			html = html + "</span>";
+		}
+		return html;
+	};
+
 	// Simplified xhr call
 	var myXhr = function(method, path, data) {
 		if (!data) {
 			data = {};
 		}
 		
-		return xhr(method, path, {
+		return xhr(method, basePath + path, {
 			headers: {},
 			timeout: 60000,
 			data: JSON.stringify(data)
@@ -729,8 +783,12 @@ define(['orion/xhr'], function(xhr) {
 	
 	var outputArea = document.getElementById("outputArea");
 	
-	var wsUrl = document.URL.replace("http://", "ws://") + "output";
-	wsUrl = wsUrl.replace("https://", "wss://");
+	// A core dump never stops so its threads are shown right away
+	if (config.Core) {
+		allThreadsWidget.handleAllThreadsStopped("all");
+	}
+	
+	var wsUrl = window.location.protocol.replace("http", "ws") + "//" + window.location.host + basePath + "/output";
 	var websocket = new WebSocket(wsUrl);
 	//websocket.onopen = function(evt) {  };
 	websocket.onclose = function(evt) {
@@ -758,6 +816,19 @@ define(['orion/xhr'], function(xhr) {
 			
 			outputArea.innerHTML = outputArea.innerHTML + "[" + type + "] " + message;
 			
+			outputArea.scrollIntoView(false);
+		} else if (type === "target-raw") {
+			// The program's output exactly as it was written, which may be colored
+			var bytes = window.atob(event.Data);
+			var text;
+			try {
+				text = decodeURIComponent(window.escape(bytes));
+			} catch (e) {
+				text = bytes;
+			}
+			
+			outputArea.innerHTML = outputArea.innerHTML + "[target] " + ansiToHtml(text);
+			
 			outputArea.scrollIntoView(false);
 		} else if (type === "async") {
 			// Asynchronous result record
@@ -774,7 +845,26 @@ define(['orion/xhr'], function(xhr) {
 				if (record.Result.reason && record.Result.reason.substring(0,6) !== "exited") {
 					// All threads are stopped in all-stop mode
 					allThreadsWidget.handleAllThreadsStopped(threadId);
+					
+					if (pendingBookmark) {
+						myXhr("POST", "/handle/gdb/bookmark/load", {
+							Token: pendingBookmark
+						}).then(function(result) {
+							var resultObj = JSON.parse(result.response);
+							
+							allThreadsWidget.selectThread(resultObj.Thread);
+						}, handleXhrError);
+						
+						pendingBookmark = null;
+					}
 				}
+			} else if (record.Indication === "library-loaded" || record.Indication === "library-unloaded") {
+				var action = record.Indication === "library-loaded" ? "loaded " : "unloaded ";
+				var targetName = (record.Result['target-name'] || "").replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;");
+				
+				outputArea.innerHTML = outputArea.innerHTML + "[library] " + action + targetName + "\n";
+				
+				outputArea.scrollIntoView(false);
 			} else if (record.Indication === "running") {
 				var threadId = record.Result['thread-id'];
 				
@@ -0,0 +1,224 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/sirnewton01/gdblib"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+var errNotStoppedOnSignal = errors.New("The program isn't stopped on a signal")
+
+type crashThread struct {
+	Id     string
+	Frames []backtraceFrame
+}
+
+// Everything about where the program crashed that is useful for a bug report
+type crashReport struct {
+	Signal        string
+	SignalMeaning string
+	Thread        string
+	Location      stopLocation
+	Threads       []crashThread
+	// The registers, instructions and locals are those of the innermost frame
+	//  of the thread that received the signal.
+	Registers   []frameRegister
+	Disassembly []stopInstruction
+	Locals      []map[string]interface{}
+}
+
+func threadFrames(mygdb *tracedGDB, threadId string) ([]backtraceFrame, error) {
+	stackResult, err := mygdb.StackListFrames(gdblib.StackListFramesParms{ThreadId: threadId})
+	if err != nil {
+		return nil, err
+	}
+
+	stack := struct {
+		Stack []backtraceFrame `json:"stack"`
+	}{}
+
+	err = remarshal(stackResult, &stack)
+	return stack.Stack, err
+}
+
+// Matches the signal that a core dump was written for in the info program
+// output (e.g. "It stopped at signal SIGSEGV, Segmentation fault.")
+var coreSignalPattern = regexp.MustCompile(`signal (SIG[A-Z0-9]+), ([^.\n]+)`)
+
+// Finds where the program of the core dump was when it received the signal
+// that killed it. There is no stop record for a core dump so it comes from
+// gdb's description of the program and the innermost frame of the thread
+// that is selected when the core is loaded, the one that got the signal.
+func coreStop(mygdb *tracedGDB) (stopLocation, error) {
+	stop := stopLocation{Reason: "signal-received"}
+
+	cliOutput, err := cliExec(mygdb, "info program")
+	if match := coreSignalPattern.FindStringSubmatch(cliOutput); err == nil && match != nil {
+		stop.Signal = match[1]
+		stop.SignalMeaning = match[2]
+	} else {
+		// Only the number is known without the description
+		signo, err := evaluateInt(mygdb, "$_siginfo.si_signo")
+		if err != nil || signo == 0 {
+			return stop, errNotStoppedOnSignal
+		}
+		stop.Signal = strconv.Itoa(signo)
+	}
+
+	idsResult, err := mygdb.ThreadListIds()
+	if err != nil {
+		return stop, err
+	}
+
+	ids := struct {
+		CurrentThreadId string `json:"current-thread-id"`
+	}{}
+
+	err = remarshal(idsResult, &ids)
+	if err != nil {
+		return stop, err
+	}
+	stop.Thread = ids.CurrentThreadId
+
+	frames, err := threadFrames(mygdb, stop.Thread)
+	if err != nil {
+		return stop, err
+	}
+	if len(frames) > 0 {
+		stop.Func = frames[0].Func
+		stop.File = frames[0].File
+		stop.Line = frames[0].Line
+		stop.Addr = frames[0].Addr
+	}
+
+	return stop, nil
+}
+
+// Gathers the crash report for the signal that the program is stopped on, or
+// that killed it when a core dump is being inspected
+func buildCrashReport(mygdb *tracedGDB) (*crashReport, error) {
+	var stop stopLocation
+	if *coreFile != "" {
+		var err error
+		stop, err = coreStop(mygdb)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var ok bool
+		stop, ok = recentStops.stoppedAt()
+		if !ok || stop.Reason != "signal-received" || stop.Interrupted {
+			return nil, errNotStoppedOnSignal
+		}
+	}
+
+	report := &crashReport{Signal: stop.Signal, SignalMeaning: stop.SignalMeaning, Thread: stop.Thread,
+		Location: stop, Threads: []crashThread{}, Registers: []frameRegister{},
+		Disassembly: []stopInstruction{}, Locals: []map[string]interface{}{}}
+
+	idsResult, err := mygdb.ThreadListIds()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := struct {
+		ThreadIds []string `json:"thread-ids"`
+	}{}
+
+	err = remarshal(idsResult, &ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, threadId := range ids.ThreadIds {
+		// A thread that can't be unwound is still listed
+		frames, err := threadFrames(mygdb, threadId)
+		if err != nil {
+			frames = []backtraceFrame{}
+		}
+		report.Threads = append(report.Threads, crashThread{threadId, frames})
+	}
+
+	registers, err := frameRegisters(mygdb, stop.Thread, "0")
+	if err == nil {
+		report.Registers = registers
+	}
+
+	if instructions := stopDisassembly(mygdb, stop.Addr); instructions != nil {
+		report.Disassembly = instructions
+	}
+
+	varsResult, err := mygdb.StackListVariables(gdblib.StackListVariablesParms{Thread: stop.Thread,
+		Frame: "0", AllValues: true})
+	if err == nil {
+		vars := struct {
+			Variables []map[string]interface{} `json:"variables"`
+		}{}
+
+		if remarshal(varsResult, &vars) == nil && vars.Variables != nil {
+			report.Locals = vars.Variables
+		}
+	}
+
+	return report, nil
+}
+
+// Lays out the crash report as plain text for pasting into a bug report
+func crashReportText(report *crashReport) string {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "Program received signal %v, %v.\n", report.Signal, report.SignalMeaning)
+	fmt.Fprintf(&buf, "Thread %v at %v (%v:%v) %v\n", report.Thread, report.Location.Func,
+		report.Location.File, report.Location.Line, report.Location.Addr)
+
+	fmt.Fprintf(&buf, "\nInstructions:\n")
+	for _, instruction := range report.Disassembly {
+		marker := "  "
+		if instruction.IsCurrentPC {
+			marker = "=>"
+		}
+		fmt.Fprintf(&buf, "%v %v %v\t%v\n", marker, instruction.Address, instruction.Function, instruction.Instruction)
+	}
+
+	fmt.Fprintf(&buf, "\nRegisters:\n")
+	for _, register := range report.Registers {
+		fmt.Fprintf(&buf, "%-15v %-20v %v\n", register.Name, register.Value, register.Natural)
+	}
+
+	fmt.Fprintf(&buf, "\nLocals:\n")
+	for _, local := range report.Locals {
+		fmt.Fprintf(&buf, "%v = %v\n", local["name"], local["value"])
+	}
+
+	threads := append([]crashThread{}, report.Threads...)
+	sort.Sort(byThreadId(threads))
+
+	for _, thread := range threads {
+		fmt.Fprintf(&buf, "\nThread %v:\n", thread.Id)
+		for _, frame := range thread.Frames {
+			fmt.Fprintf(&buf, "#%-3v %v %v at %v:%v\n", frame.Level, frame.Addr, frame.Func, frame.File, frame.Line)
+		}
+	}
+
+	return buf.String()
+}
+
+type byThreadId []crashThread
+
+func (t byThreadId) Len() int { return len(t) }
+func (t byThreadId) Less(i, j int) bool {
+	// Thread ids are numbers so shorter ones come first
+	if len(t[i].Id) != len(t[j].Id) {
+		return len(t[i].Id) < len(t[j].Id)
+	}
+	return t[i].Id < t[j].Id
+}
+func (t byThreadId) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
@@ -0,0 +1,86 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// Number of previous values kept for each display expression
+	maxDisplayHistory = 50
+)
+
+// Matches the value of a display expression that gdb prints when the
+// program stops (e.g. "1: x = 5" or "2: /x y = 0x1f")
+var displayValuePattern = regexp.MustCompile(`^([0-9]+): (.*?) = (.*)$`)
+
+type displayValues struct {
+	Number     int
+	Expression string
+	Values     []string
+}
+
+// Recent values of each display expression keyed by the display number
+type displayHistory struct {
+	mutex  sync.Mutex
+	values map[int]*displayValues
+}
+
+var displays = displayHistory{values: make(map[int]*displayValues)}
+
+// Records the value if the console line is one of the display expressions
+func (d *displayHistory) record(line string) {
+	match := displayValuePattern.FindStringSubmatch(strings.TrimRight(line, "\n"))
+	if match == nil {
+		return
+	}
+
+	number, _ := strconv.Atoi(match[1])
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	history := d.values[number]
+	if history == nil || history.Expression != match[2] {
+		history = &displayValues{Number: number, Expression: match[2]}
+		d.values[number] = history
+	}
+
+	if len(history.Values) >= maxDisplayHistory {
+		history.Values = history.Values[1:]
+	}
+	history.Values = append(history.Values, match[3])
+}
+
+func (d *displayHistory) get(number int) (displayValues, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	history := d.values[number]
+	if history == nil {
+		return displayValues{}, false
+	}
+
+	return displayValues{history.Number, history.Expression,
+		append([]string{}, history.Values...)}, true
+}
+
+func (d *displayHistory) remove(number int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	delete(d.values, number)
+}
+
+func (d *displayHistory) removeAll() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.values = make(map[int]*displayValues)
+}
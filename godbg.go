@@ -5,15 +5,17 @@
 package main
 
 import (
+	"bytes"
 	"golang.org/x/net/websocket"
 	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"github.com/sirnewton01/gdblib"
 	"go/build"
-	"io"
+	"io/ioutil"
 	"log"
 	"math/rand"
 	"net"
@@ -21,10 +23,15 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
 )
 
 type chainedFileSystem struct {
@@ -61,12 +68,29 @@ const (
 var (
 	srcDir    *string
 	autoOpen  *bool
+	basePath  *string
 	gopath    string
 	gopaths   []string
 	goroot    string
 	cwd       string
 	bundleDir string
 
+	executablePath string
+
+	transcriptFile *string
+	stopLogPath    *string
+	nonStop        *bool
+	allowWrite     *bool
+	noBrowser      *bool
+	showQRCode     *bool
+	coreFile       *string
+	rawOutput      *bool
+	sourceCacheMb  *int
+
+	interruptOnConnect *bool
+
+	output *broadcaster
+
 	magicKey string
 	hostName string = loopbackHost
 	certFile string
@@ -80,13 +104,54 @@ func init() {
 	}
 	srcDir = flag.String("srcDir", "", "Location of the source code for the executable")
 	autoOpen = flag.Bool("openBrowser", true, "Automatically open a web browser when possible")
+	basePath = flag.String("base-path", "", "Path prefix for all urls when served behind a reverse proxy (e.g. /godbg)")
+	transcriptFile = flag.String("transcript", "", "File to append a transcript of all of the commands and their results")
+	stopLogPath = flag.String("stop-log", "", "File to append a JSON line to each time the program stops with where it stopped and the values of the displays")
+	nonStop = flag.Bool("non-stop", false, "Debug in non-stop mode where the other threads keep running when one stops")
+	noBrowser = flag.Bool("no-browser", false, "Don't open a web browser, same as -openBrowser=false")
+	showQRCode = flag.Bool("qr", false, "Print a QR code of the url to the terminal")
+	interruptOnConnect = flag.Bool("interrupt-on-connect", false, "Pause the program when a web UI connects while it is running")
+	allowWrite = flag.Bool("allow-write", false, "Allow the handlers that call functions in the program or write to its memory, the gdb console can still change anything")
+	coreFile = flag.String("core", "", "Core dump of the executable to inspect instead of running it")
+	sourceCacheMb = flag.Int("source-cache-mb", 32, "Megabytes of source files kept in memory so that they aren't read from disk on every stop, 0 turns off the cache")
+	rawOutput = flag.Bool("raw-output", false, "Send the program's output to the web UI base64 encoded exactly as it was written (e.g. with ANSI colors)")
 
 	flag.Parse()
 
+	// The base path is kept with a leading slash and no trailing slash so that
+	//  it can be prepended to any of the absolute paths.
+	if *basePath != "" {
+		trimmedPath := "/" + strings.Trim(*basePath, "/")
+		if trimmedPath == "/" {
+			trimmedPath = ""
+		}
+		basePath = &trimmedPath
+	}
+
 	gopath = build.Default.GOPATH
 	goroot = runtime.GOROOT()
 	cwd, _ = os.Getwd()
 
+	if *transcriptFile != "" {
+		file, err := os.OpenFile(*transcriptFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			log.Fatalf("Could not open the transcript file: %v\n", err)
+		}
+		sessionTranscript.file = file
+	}
+
+	if *stopLogPath != "" {
+		file, err := os.OpenFile(*stopLogPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			log.Fatalf("Could not open the stop log file: %v\n", err)
+		}
+		stopLogFile = file
+	}
+
+	if *interruptOnConnect {
+		atomic.StoreInt32(&interruptOnConnectEnabled, 1)
+	}
+
 	// Search gopaths for the bundles directory for our web bundles
 	gopaths = strings.Split(gopath, string(filepath.ListSeparator))
 	for _, path := range gopaths {
@@ -178,10 +243,16 @@ func main() {
 		}
 	}
 
-	mygdb, err := gdblib.NewGDB(execPath, *srcDir)
+	executablePath = execPath
+
+	gdb, err := gdblib.NewGDB(execPath, *srcDir)
 	if err != nil {
 		panic(err)
 	}
+	mygdb := &tracedGDB{gdb}
+
+	output = newBroadcaster(mygdb)
+	go output.run()
 
 	serverAddrChan := make(chan string)
 
@@ -197,65 +268,39 @@ func main() {
 		http.HandleFunc("/", wrapFileServer(http.FileServer(cfs)))
 
 		http.HandleFunc("/output", wrapWebSocket(websocket.Handler(func(ws *websocket.Conn) {
-			type webSockResult struct {
-				Type string
-				Data interface{}
+			client, id := output.subscribe()
+			defer output.unsubscribe(client)
+
+			if atomic.LoadInt32(&interruptOnConnectEnabled) == 1 {
+				go interruptIfRunning(mygdb)
+			}
+
+			// The client needs its id to change which messages it gets
+			bytes, err := json.Marshal(webSockResult{Type: "connection", Data: struct{ Id int }{id}})
+			if err == nil {
+				ws.Write(bytes)
 			}
 
 			for {
+				var msg webSockResult
+
 				select {
-				case data := <-mygdb.Console:
-					bytes, err := json.Marshal(&webSockResult{Type: "console", Data: data})
-					if err == nil {
-						_, err := ws.Write(bytes)
-						if err != nil {
-							fmt.Printf("Client disconnect\n")
-							mygdb.GdbExit()
-						}
-					}
-					// TODO log the marshalling error
-				case data := <-mygdb.Target:
-					bytes, err := json.Marshal(&webSockResult{Type: "target", Data: data})
-					if err == nil {
-						_, err := ws.Write(bytes)
-						if err != nil {
-							fmt.Printf("Client disconnect\n")
-							mygdb.GdbExit()
-						}
-					}
-					// TODO log the marshalling error
-				case data := <-mygdb.InternalLog:
-					bytes, err := json.Marshal(&webSockResult{Type: "gdb", Data: data})
-					if err == nil {
-						_, err := ws.Write(bytes)
-						if err != nil {
-							fmt.Printf("Client disconnect\n")
-							mygdb.GdbExit()
-						}
-					}
-					// TODO log the marshalling error
-				case record := <-mygdb.AsyncResults:
-					bytes, err := json.Marshal(&webSockResult{Type: "async", Data: record})
-					if err == nil {
-						_, err := ws.Write(bytes)
-						if err != nil {
-							fmt.Printf("Client disconnect\n")
-							mygdb.GdbExit()
-						}
-					}
-					// TODO log the marshalling error
+				case msg = <-client:
 				case <-time.After(30 * time.Second):
 					// Send heartbeat and disconnect if client doesn't receive it
-					bytes, err := json.Marshal(&webSockResult{Type: "heartbeat", Data: ""})
-					if err == nil {
-						_, err := ws.Write(bytes)
-						if err != nil {
-							fmt.Printf("Client disconnect\n")
-							mygdb.GdbExit()
-						}
+					msg = webSockResult{Type: "heartbeat", Data: ""}
+				}
+
+				bytes, err := json.Marshal(&msg)
+				if err == nil {
+					_, err := ws.Write(bytes)
+					if err != nil {
+						fmt.Printf("Client disconnect\n")
+						mygdb.GdbExit()
+						return
 					}
-					// TODO log the marshalling error
 				}
+				// TODO log the marshalling error
 			}
 		})))
 
@@ -265,11 +310,44 @@ func main() {
 		addThreadHandlers(mygdb)
 		addFrameHandlers(mygdb)
 		addVariableHandlers(mygdb)
-
-		http.HandleFunc("/handle/gdb/exit", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addDataHandlers(mygdb)
+		addGdbHandlers(mygdb)
+		addCheckpointHandlers(mygdb)
+		addWatchpointHandlers(mygdb)
+		addConsoleHandlers(mygdb)
+		addTargetHandlers(mygdb)
+		addTracepointHandlers(mygdb)
+		addSnapshotHandlers(mygdb)
+		addGoroutineHandlers(mygdb)
+		addSymbolHandlers(mygdb)
+		addRecordHandlers(mygdb)
+		addInferiorHandlers(mygdb)
+		addSkipHandlers(mygdb)
+
+		handleFunc("/handle/gdb/exit", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			mygdb.GdbExit()
 		}))
 
+		// Configuration needed by the web UI to construct its urls
+		handleFunc("/handle/gdb/config", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			config := struct {
+				BasePath string
+				Core     bool
+			}{*basePath, *coreFile != ""}
+
+			resultBytes, err := json.Marshal(config)
+
+			if err != nil {
+				w.WriteHeader(500)
+				w.Write([]byte(err.Error()))
+			} else {
+				w.WriteHeader(200)
+				w.Write(resultBytes)
+			}
+		}))
+
+		handler := wrapBasePath(http.DefaultServeMux)
+
 		// Unsecure local connection through the loopback interface
 		if hostName == loopbackHost {
 			listener, err := net.Listen("tcp", hostName+":0")
@@ -279,7 +357,7 @@ func main() {
 
 			serverAddrChan <- listener.Addr().String()
 
-			http.Serve(listener, nil)
+			http.Serve(listener, handler)
 		} else {
 			// Secure connection requires a SSL/TLS cerificate and key
 			config := &tls.Config{}
@@ -300,7 +378,7 @@ func main() {
 
 			serverAddrChan <- strings.Replace(listener.Addr().String(), loopbackHost, hostName, 1)
 
-			http.Serve(listener, nil)
+			http.Serve(listener, handler)
 		}
 	}()
 
@@ -308,21 +386,60 @@ func main() {
 		serverAddr := <-serverAddrChan
 		url := ""
 		if hostName != loopbackHost {
-			url = "https://" + serverAddr + "/?MAGIC=" + magicKey
+			url = "https://" + serverAddr + *basePath + "/?MAGIC=" + magicKey
 		} else {
-			url = "http://" + serverAddr
+			url = "http://" + serverAddr + *basePath + "/"
+		}
+
+		// The url is always printed so that it can be used from another
+		//  machine or if the browser cannot be opened.
+		fmt.Printf("%v\n", url)
+		if *showQRCode {
+			printQRCode(url)
 		}
 
-		if *autoOpen {
+		if *autoOpen && !*noBrowser {
 			openBrowser(url)
-		} else {
-			fmt.Printf("%v\n", url)
 		}
 	}()
 
-	execArgs := flag.Args()[1:]
-	mygdb.ExecArgs(gdblib.ExecArgsParms{strings.Join(execArgs, " ")})
-	mygdb.ExecRun(gdblib.ExecRunParms{})
+	// Non-stop mode can only be changed before the program is started
+	if *nonStop {
+		err = mygdb.GdbSet(gdblib.GdbSetParms{Variable: "target-async", Value: "on"})
+		if err == nil {
+			err = mygdb.GdbSet(gdblib.GdbSetParms{Variable: "non-stop", Value: "on"})
+		}
+		if err != nil {
+			log.Fatalf("Could not enable non-stop mode: %v\n", err)
+		}
+	}
+
+	// Asynchronous mode lets the program be interrupted while it is running.
+	//  Older versions of gdb only have the target-async setting.
+	err = mygdb.GdbSet(gdblib.GdbSetParms{Variable: "mi-async", Value: "on"})
+	if err != nil {
+		mygdb.GdbSet(gdblib.GdbSetParms{Variable: "target-async", Value: "on"})
+	}
+
+	// Commands that would ask for confirmation go straight ahead instead,
+	//  it can be turned back on through the confirm handler.
+	mygdb.GdbSet(gdblib.GdbSetParms{Variable: "confirm", Value: "off"})
+
+	// Runaway recursion would otherwise have gdb unwind every frame each time
+	//  that the stack is listed.
+	mygdb.GdbSet(gdblib.GdbSetParms{Variable: "backtrace limit", Value: strconv.Itoa(defaultBacktraceLimit)})
+
+	// A core dump is only inspected, there is no program to run
+	if *coreFile != "" {
+		_, err = cliExec(mygdb, "core-file "+*coreFile)
+		if err != nil {
+			log.Fatalf("Could not load the core dump: %v\n", err)
+		}
+	} else {
+		execArgs := flag.Args()[1:]
+		mygdb.ExecArgs(gdblib.ExecArgsParms{strings.Join(execArgs, " ")})
+		mygdb.ExecRun(gdblib.ExecRunParms{})
+	}
 
 	err = mygdb.Wait()
 	if err != nil {
@@ -332,6 +449,16 @@ func main() {
 
 type handlerFunc func(http.ResponseWriter, *http.Request)
 
+// Paths of all of the registered gdb command handlers
+var handlerPaths []string
+
+// Registers a handler keeping track of its path so that clients can
+// discover what is available with this version of godbg.
+func handleFunc(path string, delegate handlerFunc) {
+	handlerPaths = append(handlerPaths, path)
+	http.HandleFunc(path, wrapTranscript(path, wrapMetrics(path, wrapMiDebug(delegate))))
+}
+
 func getPortFromRequest(r *http.Request) string {
 	hostPort := strings.Split(r.URL.Host, ":")
 	port := "443"
@@ -341,22 +468,28 @@ func getPortFromRequest(r *http.Request) string {
 	return port
 }
 
+// Whether the request carries the magic cookie when it has to
+func authorized(r *http.Request) bool {
+	if hostName == loopbackHost {
+		return true
+	}
+
+	cookie, err := r.Cookie("MAGIC" + getPortFromRequest(r))
+	return err == nil && (*cookie).Value == magicKey
+}
+
 func wrapHandlerFunc(delegate handlerFunc) handlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if hostName != loopbackHost {
-			// Check the magic cookie
-			// Since redirection is not generally possible here if the cookie is not
-			//  present then we deny the request.
-
-			cookie, err := r.Cookie("MAGIC" + getPortFromRequest(r))
-			if err != nil || (*cookie).Value != magicKey {
-				// Denied
-				http.Error(w, "Permission Denied", 403)
-				return
-			}
+		// Check the magic cookie
+		// Since redirection is not generally possible here if the cookie is not
+		//  present then we deny the request.
+		if !authorized(r) {
+			// Denied
+			http.Error(w, "Permission Denied", 403)
+			return
 		}
 
-		delegate(w, r)
+		handleOnce(w, r, delegate)
 	}
 }
 
@@ -401,13 +534,16 @@ func wrapFileServer(delegate http.Handler) handlerFunc {
 				// Redirect to the base URL setting the cookie
 				// Cookie lasts for 1 year
 				cookie := &http.Cookie{Name: "MAGIC" + port, Value: magicKey,
-					Path: "/", Domain: hostName, MaxAge: 2000000,
+					Path: *basePath + "/", Domain: hostName, MaxAge: 2000000,
 					Secure: true, HttpOnly: false}
 
 				http.SetCookie(writer, cookie)
 
+				// The base path was stripped from the request so it is put back
+				//  for the browser.
 				urlWithoutQuery := req.URL
 				urlWithoutQuery.RawQuery = ""
+				urlWithoutQuery.Path = *basePath + urlWithoutQuery.Path
 
 				http.Redirect(writer, req, urlWithoutQuery.String(), 302)
 				return
@@ -418,8 +554,216 @@ func wrapFileServer(delegate http.Handler) handlerFunc {
 	}
 }
 
-func addThreadHandlers(mygdb *gdblib.GDB) {
-	http.HandleFunc("/handle/thread/listids", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+// Matches the first hex address in a value printed by gdb
+//  (e.g. "(int *) 0xc200000010")
+var addressPattern = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+
+// Matches an instruction line of the disassemble command output
+// (e.g. "=> 0x0000000000400c09 <+9>:	cmp    0x10(%rcx),%rsp")
+var disassemblyPattern = regexp.MustCompile(`^(=>)?\s+(0x[0-9a-fA-F]+)(?: <([^>]*)>)?:\s+(.*)$`)
+
+// Matches a source line in the output of disassemble /s or /m
+// (e.g. "12\t\tx := compute(y)")
+var mixedSourcePattern = regexp.MustCompile(`^([0-9]+)\t(.*)$`)
+
+// Matches the file name in front of the source lines in the output of
+// disassemble /s (e.g. "/home/user/src/foo/main.go:")
+var mixedFilePattern = regexp.MustCompile(`^(\S.*):$`)
+
+// Matches the start of each value in the value history
+// (e.g. "$2 = 42")
+var valueHistoryPattern = regexp.MustCompile(`(?m)^\$([0-9]+) = `)
+
+// Splits the output of print or show values into the numbered values
+func parseValueHistory(cliOutput string) map[int]string {
+	values := make(map[int]string)
+
+	matches := valueHistoryPattern.FindAllStringSubmatchIndex(cliOutput, -1)
+	for idx, match := range matches {
+		end := len(cliOutput)
+		if idx+1 < len(matches) {
+			end = matches[idx+1][0]
+		}
+
+		number, _ := strconv.Atoi(cliOutput[match[2]:match[3]])
+		values[number] = strings.TrimSpace(cliOutput[match[1]:end])
+	}
+
+	return values
+}
+
+// Matches a line of the x command's output
+// (e.g. "0x601040 <buf>:\t0x00\t0x01\t0x02")
+var memoryLinePattern = regexp.MustCompile(`^\s*(0x[0-9a-fA-F]+)(?: <[^>]*>)?:\s*(.*)$`)
+
+// Maximum number of bytes read from memory with a single request
+const maxMemoryRead = 65536
+
+// Reads memory starting at the address that the expression evaluates to.
+// Reading stops at the first byte that can't be accessed so fewer bytes than
+// asked for may come back along with the error.
+func readMemory(mygdb *tracedGDB, address string, count int) (uint64, []byte, error) {
+	if count < 1 || count > maxMemoryRead {
+		return 0, nil, fmt.Errorf("Count must be between 1 and %v", maxMemoryRead)
+	}
+
+	cliOutput, err := cliExec(mygdb, "x/"+strconv.Itoa(count)+"xb "+address)
+
+	start := uint64(0)
+	memory := []byte{}
+	for _, line := range strings.Split(cliOutput, "\n") {
+		match := memoryLinePattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		if len(memory) == 0 {
+			start, _ = parseAddress(match[1])
+		}
+
+		for _, field := range strings.Fields(match[2]) {
+			value, parseErr := strconv.ParseUint(strings.TrimPrefix(field, "0x"), 16, 8)
+			if parseErr == nil {
+				memory = append(memory, byte(value))
+			}
+		}
+	}
+
+	if err == nil && len(memory) == 0 {
+		err = errors.New(strings.TrimSpace(cliOutput))
+	}
+
+	return start, memory, err
+}
+
+// A row of a hex dump of memory
+type hexdumpRow struct {
+	Address  string
+	HexBytes string
+	Ascii    string
+}
+
+// Lays out memory read from the start address as a hex dump with width bytes
+// in each row. Bytes past the end of the memory up to the count couldn't be
+// read and are shown as ?? (and ? in the ASCII column).
+func hexdumpRows(start uint64, memory []byte, count int, width int) []hexdumpRow {
+	rows := []hexdumpRow{}
+
+	for offset := 0; offset < count; offset += width {
+		hexBytes := []string{}
+		ascii := []byte{}
+
+		for idx := offset; idx < offset+width && idx < count; idx++ {
+			if idx >= len(memory) {
+				hexBytes = append(hexBytes, "??")
+				ascii = append(ascii, '?')
+				continue
+			}
+
+			value := memory[idx]
+			hexBytes = append(hexBytes, fmt.Sprintf("%02x", value))
+			if value >= 0x20 && value < 0x7f {
+				ascii = append(ascii, value)
+			} else {
+				ascii = append(ascii, '.')
+			}
+		}
+
+		rows = append(rows, hexdumpRow{fmt.Sprintf("0x%x", start+uint64(offset)),
+			strings.Join(hexBytes, " "), string(ascii)})
+	}
+
+	return rows
+}
+
+// Default and largest number of bytes in each row of a hex dump
+const defaultHexdumpWidth = 16
+const maxHexdumpWidth = 64
+
+// Converts one of the gdblib result structures into another
+//  representation of the same JSON document.
+func remarshal(in interface{}, out interface{}) error {
+	bytes, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(bytes, out)
+}
+
+// Rejects requests for the handlers that are there to modify the program
+// (calling its functions, restoring memory, loading settings and scripts)
+// unless godbg was started with -allow-write. Returns whether the request may
+// go on. This isn't a sandbox, anything typed into the gdb console or given
+// to an expression (e.g. an assignment) still goes to gdb as it is.
+func checkAllowWrite(w http.ResponseWriter) bool {
+	if !*allowWrite {
+		w.WriteHeader(403)
+		w.Write([]byte("Modifying the program is not allowed, restart godbg with -allow-write"))
+		return false
+	}
+
+	return true
+}
+
+func wrapBasePath(delegate http.Handler) http.Handler {
+	if *basePath == "" {
+		return delegate
+	}
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		// The web UI uses relative urls so it must be loaded from a path
+		//  ending in a slash.
+		if req.URL.Path == *basePath {
+			http.Redirect(writer, req, *basePath+"/", 302)
+			return
+		}
+
+		http.StripPrefix(*basePath, delegate).ServeHTTP(writer, req)
+	})
+}
+
+// Matches the system thread id in a thread's target-id
+// (e.g. "Thread 0x7ffff7fd5740 (LWP 12345)")
+var systemTidPattern = regexp.MustCompile(`\b(?:LWP|process) ([0-9]+)\b`)
+
+// Functions that a thread stopped in is considered to be blocked waiting on
+// the system or on another thread
+var blockingFunctions = map[string]bool{
+	"epoll_wait": true, "epoll_pwait": true, "poll": true, "select": true,
+	"pselect": true, "nanosleep": true, "clock_nanosleep": true, "sleep": true,
+	"usleep": true, "futex": true, "syscall": true, "read": true, "accept": true,
+	"accept4": true, "recv": true, "recvfrom": true, "recvmsg": true, "wait": true,
+	"waitpid": true, "__lll_lock_wait": true, "pthread_cond_wait": true,
+	"pthread_cond_timedwait": true, "pthread_join": true, "sem_wait": true,
+	"runtime.futex": true, "runtime.epollwait": true, "runtime.usleep": true,
+	"runtime.notesleep": true, "runtime.kevent": true,
+}
+
+// Returns whether the thread is in the state asked for, which is either
+// running, stopped or blocked. Blocked threads are stopped in one of the
+// blocking functions and aren't included in the stopped ones.
+func threadInState(thread map[string]interface{}, state string) bool {
+	threadState, _ := thread["state"].(string)
+	if state == "running" {
+		return threadState == "running"
+	}
+	if threadState != "stopped" {
+		return false
+	}
+
+	function := ""
+	if frame, ok := thread["frame"].(map[string]interface{}); ok {
+		function, _ = frame["func"].(string)
+	}
+	// Libc symbols often carry a prefix (e.g. "__GI_epoll_wait")
+	function = strings.TrimPrefix(strings.TrimPrefix(function, "__GI_"), "__libc_")
+
+	return blockingFunctions[function] == (state == "blocked")
+}
+
+func addThreadHandlers(mygdb *tracedGDB) {
+	handleFunc("/handle/thread/listids", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		result, err := mygdb.ThreadListIds()
 
 		if err != nil {
@@ -438,7 +782,7 @@ func addThreadHandlers(mygdb *gdblib.GDB) {
 			w.Write(resultBytes)
 		}
 	}))
-	http.HandleFunc("/handle/thread/select", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handleFunc("/handle/thread/select", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		parms := gdblib.ThreadSelectParms{}
 
 		decoder := json.NewDecoder(r.Body)
@@ -468,7 +812,7 @@ func addThreadHandlers(mygdb *gdblib.GDB) {
 			w.Write(resultBytes)
 		}
 	}))
-	http.HandleFunc("/handle/thread/info", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handleFunc("/handle/thread/info", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		parms := gdblib.ThreadInfoParms{}
 
 		decoder := json.NewDecoder(r.Body)
@@ -498,11 +842,13 @@ func addThreadHandlers(mygdb *gdblib.GDB) {
 			w.Write(resultBytes)
 		}
 	}))
-}
+	handleFunc("/handle/thread/selectbytid", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Tid int
+		}{}
 
-func addFrameHandlers(mygdb *gdblib.GDB) {
-	http.HandleFunc("/handle/frame/stackinfo", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		result, err := mygdb.StackInfoFrame()
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
 
 		if err != nil {
 			w.WriteHeader(400)
@@ -510,29 +856,45 @@ func addFrameHandlers(mygdb *gdblib.GDB) {
 			return
 		}
 
-		resultBytes, err := json.Marshal(result)
+		infoResult, err := mygdb.ThreadInfo(gdblib.ThreadInfoParms{})
 
 		if err != nil {
-			w.WriteHeader(500)
+			w.WriteHeader(400)
 			w.Write([]byte(err.Error()))
-		} else {
-			w.WriteHeader(200)
-			w.Write(resultBytes)
+			return
 		}
-	}))
-	http.HandleFunc("/handle/frame/stacklist", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		parms := gdblib.StackListFramesParms{}
 
-		decoder := json.NewDecoder(r.Body)
-		err := decoder.Decode(&parms)
+		threads := struct {
+			Threads []struct {
+				Id       string `json:"id"`
+				TargetId string `json:"target-id"`
+			} `json:"threads"`
+		}{}
+
+		err = remarshal(infoResult, &threads)
 
 		if err != nil {
-			w.WriteHeader(400)
+			w.WriteHeader(500)
 			w.Write([]byte(err.Error()))
 			return
 		}
 
-		result, err := mygdb.StackListFrames(parms)
+		threadId := ""
+		for _, thread := range threads.Threads {
+			match := systemTidPattern.FindStringSubmatch(thread.TargetId)
+			if match != nil && match[1] == strconv.Itoa(parms.Tid) {
+				threadId = thread.Id
+				break
+			}
+		}
+
+		if threadId == "" {
+			w.WriteHeader(404)
+			w.Write([]byte("No thread found with that system thread id"))
+			return
+		}
+
+		result, err := mygdb.ThreadSelect(gdblib.ThreadSelectParms{ThreadId: threadId})
 
 		if err != nil {
 			w.WriteHeader(400)
@@ -550,12 +912,16 @@ func addFrameHandlers(mygdb *gdblib.GDB) {
 			w.Write(resultBytes)
 		}
 	}))
+	handleFunc("/handle/thread/list", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := r.URL.Query().Get("state")
 
-	http.HandleFunc("/handle/frame/variableslist", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		parms := gdblib.StackListVariablesParms{}
+		if state != "" && state != "running" && state != "stopped" && state != "blocked" {
+			w.WriteHeader(400)
+			w.Write([]byte("State must be one of running, stopped or blocked"))
+			return
+		}
 
-		decoder := json.NewDecoder(r.Body)
-		err := decoder.Decode(&parms)
+		infoResult, err := mygdb.ThreadInfo(gdblib.ThreadInfoParms{})
 
 		if err != nil {
 			w.WriteHeader(400)
@@ -563,14 +929,29 @@ func addFrameHandlers(mygdb *gdblib.GDB) {
 			return
 		}
 
-		result, err := mygdb.StackListVariables(parms)
+		result := struct {
+			Threads         []map[string]interface{} `json:"threads"`
+			CurrentThreadId string                   `json:"current-thread-id"`
+		}{}
+
+		err = remarshal(infoResult, &result)
 
 		if err != nil {
-			w.WriteHeader(400)
+			w.WriteHeader(500)
 			w.Write([]byte(err.Error()))
 			return
 		}
 
+		if state != "" {
+			threads := []map[string]interface{}{}
+			for _, thread := range result.Threads {
+				if threadInState(thread, state) {
+					threads = append(threads, thread)
+				}
+			}
+			result.Threads = threads
+		}
+
 		resultBytes, err := json.Marshal(result)
 
 		if err != nil {
@@ -581,12 +962,11 @@ func addFrameHandlers(mygdb *gdblib.GDB) {
 			w.Write(resultBytes)
 		}
 	}))
+}
 
-	http.HandleFunc("/handle/file/get", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		parms := make(map[string]string)
-
-		decoder := json.NewDecoder(r.Body)
-		err := decoder.Decode(&parms)
+func addFrameHandlers(mygdb *tracedGDB) {
+	handleFunc("/handle/frame/stackinfo", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := mygdb.StackInfoFrame()
 
 		if err != nil {
 			w.WriteHeader(400)
@@ -594,162 +974,6918 @@ func addFrameHandlers(mygdb *gdblib.GDB) {
 			return
 		}
 
-		path := parms["File"]
+		resultBytes, err := json.Marshal(result)
 
-		if path == "" {
-			w.WriteHeader(400)
-			w.Write([]byte("No path provided"))
-			return
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
 		}
+	}))
+	handleFunc("/handle/frame/stacklist", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := gdblib.StackListFramesParms{}
 
-		path, err = filepath.Abs(path)
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
 
-		inGopath := false
-		for _, p := range gopaths {
-			if strings.HasPrefix(path, p) {
-				inGopath = true
-				break
-			}
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
 		}
 
-		// If the path is not under the current directory or in the GOPATH/GOROOT then it is an illegal access
-		if !inGopath &&
-			!strings.HasPrefix(path, cwd) &&
-			!strings.HasPrefix(path, goroot) {
+		// Gdb stops unwinding at the backtrace limit so no more frames than
+		//  that are listed.
+		result, err := mygdb.StackListFrames(parms)
 
+		if err != nil {
 			w.WriteHeader(400)
-			w.Write([]byte("Illegal file access"))
+			w.Write([]byte(err.Error()))
 			return
 		}
 
-		file, err := os.Open(path)
+		resultBytes, err := json.Marshal(result)
 
 		if err != nil {
 			w.WriteHeader(500)
 			w.Write([]byte(err.Error()))
 		} else {
 			w.WriteHeader(200)
-			_, err := io.Copy(w, file)
-
-			if err != nil {
-				w.WriteHeader(500)
-				w.Write([]byte(err.Error()))
-			}
+			w.Write(resultBytes)
 		}
 	}))
-}
 
-func addExecHandlers(mygdb *gdblib.GDB) {
-	http.HandleFunc("/handle/exec/next", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		parms := gdblib.ExecNextParms{}
+	handleFunc("/handle/frame/variableslist", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := gdblib.StackListVariablesParms{}
 
 		decoder := json.NewDecoder(r.Body)
 		err := decoder.Decode(&parms)
 
-		if err == nil {
-			err = mygdb.ExecNext(parms)
-		}
-
 		if err != nil {
 			w.WriteHeader(400)
 			w.Write([]byte(err.Error()))
 			return
 		}
-		w.WriteHeader(200)
-	}))
-
-	http.HandleFunc("/handle/exec/step", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		parms := gdblib.ExecStepParms{}
 
-		decoder := json.NewDecoder(r.Body)
-		err := decoder.Decode(&parms)
-
-		if err == nil {
-			err = mygdb.ExecStep(parms)
-		}
+		result, err := mygdb.StackListVariables(parms)
 
 		if err != nil {
 			w.WriteHeader(400)
 			w.Write([]byte(err.Error()))
 			return
 		}
-		w.WriteHeader(200)
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
 	}))
 
-	http.HandleFunc("/handle/exec/continue", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		parms := gdblib.ExecContinueParms{}
+	handleFunc("/handle/frame/variableaddrs", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := gdblib.StackListVariablesParms{}
 
 		decoder := json.NewDecoder(r.Body)
 		err := decoder.Decode(&parms)
 
-		if err == nil {
-			err = mygdb.ExecContinue(parms)
-		}
-
 		if err != nil {
 			w.WriteHeader(400)
 			w.Write([]byte(err.Error()))
 			return
 		}
+
+		result, err := mygdb.StackListVariables(parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		variables := struct {
+			Variables []map[string]interface{} `json:"variables"`
+		}{}
+
+		err = remarshal(result, &variables)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		for _, variable := range variables.Variables {
+			name, _ := variable["name"].(string)
+
+			// Variables that live in a register have no address and gdb
+			//  reports an error for them. They are left without one. The
+			//  address is taken in the frame that the variables came from
+			//  without selecting it.
+			addrParms := gdblib.DataEvaluateExpressionParms{Thread: parms.Thread, Frame: parms.Frame, Expression: "&" + name}
+			addrResult, err := mygdb.DataEvaluateExpression(addrParms)
+			if err == nil {
+				addr := addressPattern.FindString(addrResult.Value)
+				if addr != "" {
+					variable["addr"] = addr
+				}
+			}
+		}
+
+		resultBytes, err := json.Marshal(variables)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/frame/source", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Context int
+		}{10}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Context < 0 {
+			w.WriteHeader(400)
+			w.Write([]byte("Context can't be negative"))
+			return
+		}
+
+		frameResult, err := mygdb.StackInfoFrame()
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		frame := struct {
+			Frame struct {
+				File     string `json:"file"`
+				Fullname string `json:"fullname"`
+				Line     string `json:"line"`
+			} `json:"frame"`
+		}{}
+
+		err = remarshal(frameResult, &frame)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		path := frame.Frame.Fullname
+		if path == "" {
+			path = frame.Frame.File
+		}
+
+		path, err = sourceFilePath(path)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		line, err := strconv.Atoi(frame.Frame.Line)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte("No line information for the current frame"))
+			return
+		}
+
+		contents, err := sources.read(path)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		lines := strings.Split(string(contents), "\n")
+
+		// The file may have changed since the program was built so the line
+		//  is kept within it.
+		if line < 1 {
+			line = 1
+		}
+		if line > len(lines) {
+			line = len(lines)
+		}
+
+		start := line - parms.Context
+		if start < 1 {
+			start = 1
+		}
+		end := line + parms.Context
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		result := struct {
+			File      string
+			Line      int
+			StartLine int
+			Lines     []string
+		}{path, line, start, lines[start-1 : end]}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/file/get", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := make(map[string]string)
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		path, err := sourceFilePath(parms["File"])
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		contents, err := sources.read(path)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(contents)
+		}
+	}))
+
+	handleFunc("/handle/frame/selectbyfunction", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Function string
+			// Which of the matching frames to select (starting at 1) when
+			//  there is more than one of them
+			Occurrence int
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		stackResult, err := mygdb.StackListFrames(gdblib.StackListFramesParms{})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		stack := struct {
+			Stack []struct {
+				Level string `json:"level"`
+				Func  string `json:"func"`
+			} `json:"stack"`
+		}{}
+
+		err = remarshal(stackResult, &stack)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := struct {
+			Frame   string
+			Matches []string
+		}{}
+
+		for _, frame := range stack.Stack {
+			if frame.Func == parms.Function {
+				result.Matches = append(result.Matches, frame.Level)
+			}
+		}
+
+		if len(result.Matches) == 0 {
+			w.WriteHeader(404)
+			w.Write([]byte("No frame found for function " + parms.Function))
+			return
+		}
+
+		if parms.Occurrence == 0 && len(result.Matches) == 1 {
+			parms.Occurrence = 1
+		}
+
+		// With recursion the caller has to pick which of the frames it wants
+		if parms.Occurrence < 1 || parms.Occurrence > len(result.Matches) {
+			resultBytes, err := json.Marshal(result)
+
+			if err != nil {
+				w.WriteHeader(500)
+				w.Write([]byte(err.Error()))
+			} else {
+				w.WriteHeader(409)
+				w.Write(resultBytes)
+			}
+			return
+		}
+
+		result.Frame = result.Matches[parms.Occurrence-1]
+
+		_, err = cliExec(mygdb, "frame "+result.Frame)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/frame/registers", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Thread   string
+			FrameNum int
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// Without a thread the registers are those of the selected one
+		if parms.Thread == "" {
+			idsResult, err := mygdb.ThreadListIds()
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			ids := struct {
+				CurrentThreadId string `json:"current-thread-id"`
+			}{}
+
+			err = remarshal(idsResult, &ids)
+
+			if err != nil {
+				w.WriteHeader(500)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			parms.Thread = ids.CurrentThreadId
+		}
+
+		// Registers are reported as they were saved for the frame
+		result, err := frameRegisters(mygdb, parms.Thread, strconv.Itoa(parms.FrameNum))
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/frame/allstacks", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			MaxFrames int
+		}{5}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		idsResult, err := mygdb.ThreadListIds()
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		ids := struct {
+			ThreadIds []string `json:"thread-ids"`
+		}{}
+
+		err = remarshal(idsResult, &ids)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		type threadStack struct {
+			Frames []interface{}
+			Error  string `json:",omitempty"`
+		}
+
+		// Only the frames that are returned are unwound, a thread that can't
+		//  be (e.g. one that is running) gets the error instead.
+		stackParms := gdblib.StackListFramesParms{}
+		if parms.MaxFrames > 0 {
+			stackParms.LowFrame = "0"
+			stackParms.HighFrame = strconv.Itoa(parms.MaxFrames - 1)
+		}
+
+		result := make(map[string]threadStack)
+
+		for _, threadId := range ids.ThreadIds {
+			stackParms.ThreadId = threadId
+			stackResult, err := mygdb.StackListFrames(stackParms)
+			if err != nil {
+				result[threadId] = threadStack{Frames: []interface{}{}, Error: err.Error()}
+				continue
+			}
+
+			stack := struct {
+				Stack []interface{} `json:"stack"`
+			}{}
+
+			err = remarshal(stackResult, &stack)
+			if err != nil {
+				result[threadId] = threadStack{Frames: []interface{}{}, Error: err.Error()}
+				continue
+			}
+
+			if stack.Stack == nil {
+				stack.Stack = []interface{}{}
+			}
+			result[threadId] = threadStack{Frames: stack.Stack}
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/frame/stacklistpc", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := gdblib.StackListFramesParms{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		stackResult, err := mygdb.StackListFrames(parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		stack := struct {
+			Stack []backtraceFrame `json:"stack"`
+		}{}
+
+		err = remarshal(stackResult, &stack)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		type framePC struct {
+			Level int
+			PC    string
+			Func  string
+			File  string
+			Line  int
+		}
+
+		// The addresses are written out the same way for every frame so that
+		//  samples taken at different stops can be added up by PC.
+		result := []framePC{}
+		for _, frame := range stack.Stack {
+			level, _ := strconv.Atoi(frame.Level)
+			line, _ := strconv.Atoi(frame.Line)
+
+			pc := frame.Addr
+			addr, ok := parseAddress(frame.Addr)
+			if ok {
+				pc = fmt.Sprintf("0x%x", addr)
+			}
+
+			result = append(result, framePC{level, pc, frame.Func, frame.File, line})
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/file/packages", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cliOutput, err := cliExec(mygdb, "info sources")
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := packageTree(parseSourceFiles(cliOutput))
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/file/cacheclear", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Modified files are read again anyway, this is for when the
+		//  modification time can't be trusted (e.g. some network file systems).
+		sources.clear()
+
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/file/sourceinfo", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			File string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.File == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No file provided"))
+			return
+		}
+
+		stackResult, err := mygdb.StackListFrames(gdblib.StackListFramesParms{})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		stack := struct {
+			Stack []struct {
+				Level    string `json:"level"`
+				File     string `json:"file"`
+				Fullname string `json:"fullname"`
+			} `json:"stack"`
+		}{}
+
+		err = remarshal(stackResult, &stack)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// Info source only describes the file of the selected frame so one of
+		//  the frames has to be in the file, the innermost one is used.
+		frameNum := -1
+		for _, frame := range stack.Stack {
+			if frame.Fullname == parms.File || frame.File == parms.File ||
+				(frame.Fullname != "" && filepath.Base(frame.Fullname) == parms.File) {
+				frameNum, _ = strconv.Atoi(frame.Level)
+				break
+			}
+		}
+
+		if frameNum < 0 {
+			w.WriteHeader(404)
+			w.Write([]byte("No frame found in file " + parms.File))
+			return
+		}
+
+		var info *sourceInfo
+		err = withFrame(mygdb, frameNum, func() error {
+			cliOutput, err := cliExec(mygdb, "info source")
+			if err != nil {
+				return err
+			}
+
+			info = parseSourceInfo(cliOutput)
+			return nil
+		})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		resultBytes, err := json.Marshal(info)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+}
+
+type frameRegister struct {
+	Name    string
+	Value   string
+	Natural string
+}
+
+// Reads the registers of a thread as they were saved for one of its frames
+// without changing which thread or frame is selected
+func frameRegisters(mygdb *tracedGDB, thread string, frame string) ([]frameRegister, error) {
+	namesResult, err := mygdb.DataListRegisterNames(gdblib.DataListRegisterNamesParms{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := struct {
+		RegisterNames []string `json:"register-names"`
+	}{}
+
+	err = remarshal(namesResult, &names)
+	if err != nil {
+		return nil, err
+	}
+
+	type registerValue struct {
+		Number string `json:"number"`
+		Value  string `json:"value"`
+	}
+
+	// Each register comes in hex and in its natural format
+	values := make(map[string][]registerValue)
+	for _, format := range []string{"x", "N"} {
+		valuesResult, err := mygdb.DataListRegisterValues(gdblib.DataListRegisterValuesParms{Thread: thread,
+			Frame: frame, Format: format})
+		if err != nil {
+			return nil, err
+		}
+
+		list := struct {
+			RegisterValues []registerValue `json:"register-values"`
+		}{}
+
+		err = remarshal(valuesResult, &list)
+		if err != nil {
+			return nil, err
+		}
+		values[format] = list.RegisterValues
+	}
+
+	natural := make(map[string]string)
+	for _, value := range values["N"] {
+		natural[value.Number] = value.Value
+	}
+
+	registers := []frameRegister{}
+	for _, value := range values["x"] {
+		number, err := strconv.Atoi(value.Number)
+		// Gdb leaves the names of the registers that a target doesn't have
+		//  empty.
+		if err != nil || number >= len(names.RegisterNames) || names.RegisterNames[number] == "" {
+			continue
+		}
+		registers = append(registers, frameRegister{names.RegisterNames[number], value.Value, natural[value.Number]})
+	}
+
+	return registers, nil
+}
+
+// Matches a line of the info source output
+// (e.g. "Producer is GNU C17 11.2.0 -mtune=generic -g -O2.")
+var sourceInfoPattern = regexp.MustCompile(`^(Current source file|Source language|Producer|Compiled with) (?:is )?(.*?)\.?$`)
+
+type sourceInfo struct {
+	File     string
+	Language string
+	Producer string
+	// The debugging format (e.g. "DWARF 5") when there is any debug info
+	DebugFormat string
+	DebugInfo   bool
+}
+
+func parseSourceInfo(cliOutput string) *sourceInfo {
+	info := &sourceInfo{}
+	for _, line := range strings.Split(cliOutput, "\n") {
+		match := sourceInfoPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+
+		switch match[1] {
+		case "Current source file":
+			info.File = match[2]
+		case "Source language":
+			info.Language = match[2]
+		case "Producer":
+			info.Producer = match[2]
+		case "Compiled with":
+			info.DebugFormat = strings.TrimSuffix(match[2], " debugging format")
+			info.DebugInfo = true
+		}
+	}
+
+	return info
+}
+
+// Runs the function with the given frame selected and then selects the frame
+// that was selected beforehand.
+func withFrame(mygdb *tracedGDB, frameNum int, delegate func() error) error {
+	frameResult, err := mygdb.StackInfoFrame()
+	if err != nil {
+		return err
+	}
+
+	frame := struct {
+		Frame struct {
+			Level string `json:"level"`
+		} `json:"frame"`
+	}{}
+
+	err = remarshal(frameResult, &frame)
+	if err != nil {
+		return err
+	}
+
+	_, err = cliExec(mygdb, "frame "+strconv.Itoa(frameNum))
+	if err != nil {
+		return err
+	}
+
+	err = delegate()
+
+	if frame.Frame.Level != "" {
+		cliExec(mygdb, "frame "+frame.Frame.Level)
+	}
+
+	return err
+}
+
+// Resolves the absolute path of a source file making sure that it is
+// somewhere that the web UI is allowed to read from.
+func sourceFilePath(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("No path provided")
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	inGopath := false
+	for _, p := range gopaths {
+		if strings.HasPrefix(path, p) {
+			inGopath = true
+			break
+		}
+	}
+
+	// If the path is not under the current directory or in the GOPATH/GOROOT then it is an illegal access
+	if !inGopath &&
+		!strings.HasPrefix(path, cwd) &&
+		!strings.HasPrefix(path, goroot) {
+
+		return "", errors.New("Illegal file access")
+	}
+
+	return path, nil
+}
+
+// In non-stop mode the execution commands only apply to the selected thread
+// so the thread given in the request (if any) is selected first.
+func selectExecThread(mygdb *tracedGDB, r *http.Request) error {
+	if !*nonStop {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	parms := struct {
+		Thread string
+	}{}
+
+	// Any problem with the request body is reported when it is decoded into
+	//  the command's parameters.
+	if json.Unmarshal(body, &parms) != nil || parms.Thread == "" {
+		return nil
+	}
+
+	_, err = mygdb.ThreadSelect(gdblib.ThreadSelectParms{ThreadId: parms.Thread})
+	return err
+}
+
+// Matches the output of the pwd command
+// (e.g. "Working directory /home/user/src.")
+var workingDirectoryPattern = regexp.MustCompile(`Working directory (.*)\.`)
+
+// Set to 1 once gdb has detached from the program
+var detached int32
+
+// Set to 1 when the program is paused each time a web UI connects
+var interruptOnConnectEnabled int32
+
+// Pauses the program so that it can be looked at if it is running. Nothing
+// is done for a core dump or a program that gdb has detached from.
+func interruptIfRunning(mygdb *tracedGDB) {
+	if *coreFile != "" || atomic.LoadInt32(&detached) == 1 || !programRunning(mygdb) {
+		return
+	}
+
+	recentStops.expectInterrupt()
+
+	// In non-stop mode all of the threads are paused, not only the selected
+	//  one.
+	if *nonStop {
+		cliExec(mygdb, "interrupt -a")
+	} else {
+		mygdb.ExecInterrupt(gdblib.ExecInterruptParms{})
+	}
+}
+
+// Rejects commands that execute the program when inspecting a core dump.
+// Returns whether the request may go on.
+func checkNotCore(w http.ResponseWriter) bool {
+	if *coreFile != "" {
+		w.WriteHeader(409)
+		w.Write([]byte("The program can't be executed while inspecting a core dump"))
+		return false
+	}
+
+	return true
+}
+
+// Rejects execution commands when there is no program under gdb's control.
+// Returns whether the request may go on.
+func checkInferior(w http.ResponseWriter) bool {
+	if !checkNotCore(w) {
+		return false
+	}
+
+	if atomic.LoadInt32(&detached) == 1 {
+		w.WriteHeader(409)
+		w.Write([]byte("The program has been detached and is no longer under the debugger's control"))
+		return false
+	}
+
+	return true
+}
+
+// Returns the name of gdb's asynchronous mode setting, which depends on
+// the version of gdb.
+func asyncSetting(mygdb *tracedGDB) string {
+	_, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: "mi-async"})
+	if err != nil {
+		return "target-async"
+	}
+
+	return "mi-async"
+}
+
+func asyncEnabled(mygdb *tracedGDB) bool {
+	result, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: asyncSetting(mygdb)})
+	return err == nil && result.Value == "on"
+}
+
+// Matches the symbol and offset of an address in the info symbol output
+// (e.g. "main.main + 16 in section .text")
+var symbolOffsetPattern = regexp.MustCompile(`^(\S+)(?: \+ ([0-9]+))? in section`)
+
+// How long an interrupt waits for the program to stop before giving up
+const interruptStopTimeout = 2 * time.Second
+
+// Matches the process id in the output of info proc (e.g. "process 12345")
+var processIdPattern = regexp.MustCompile(`process ([0-9]+)`)
+
+// Returns the process id of the program being debugged
+func inferiorPid(mygdb *tracedGDB) (int, error) {
+	cliOutput, err := cliExec(mygdb, "info proc")
+	if err != nil {
+		return 0, err
+	}
+
+	match := processIdPattern.FindStringSubmatch(cliOutput)
+	if match == nil {
+		return 0, errors.New("The program isn't running")
+	}
+
+	return strconv.Atoi(match[1])
+}
+
+// Matches the working directory in the info proc cwd output
+// (e.g. "cwd = '/home/user/src'")
+var procCwdPattern = regexp.MustCompile(`cwd = '(.*)'`)
+
+// An open file of the program and what it refers to (e.g. a path, a socket
+// or a pipe)
+type fileDescriptor struct {
+	Fd     int
+	Target string
+}
+
+type byFd []fileDescriptor
+
+func (f byFd) Len() int           { return len(f) }
+func (f byFd) Less(i, j int) bool { return f[i].Fd < f[j].Fd }
+func (f byFd) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+
+// Matches the permissions of a mapping in the info proc mappings output,
+// which older versions of gdb leave out (e.g. "r-xp")
+var mappingPermsPattern = regexp.MustCompile(`^[r-][w-][x-][ps]$`)
+
+// How long stepping into a call may take before the request gives up waiting
+const stepIntoTimeout = 30 * time.Second
+
+// Matches the breakpoint number in the output of the tbreak command
+// (e.g. "Temporary breakpoint 3 at 0x400c10: file foo.go, line 12.")
+var temporaryBreakpointPattern = regexp.MustCompile(`Temporary breakpoint ([0-9]+) at`)
+
+// How long each instruction step of a step stream may take
+const stepStreamTimeout = 5 * time.Second
+
+// Maximum number of instructions stepped with a single step stream
+const maxStepStream = 1000
+
+// How long the program may run between breakpoint hits of a continue until
+const continueUntilTimeout = 60 * time.Second
+
+// Default and largest number of breakpoint hits a continue until goes
+// through before giving up on the condition
+const defaultContinueUntilHits = 100
+const maxContinueUntilHits = 10000
+
+// Whether the value of an expression printed by gdb counts as true
+func valueIsTrue(value string) bool {
+	value = strings.TrimSpace(value)
+	return value != "" && value != "0" && value != "false" && value != "0x0"
+}
+
+func addExecHandlers(mygdb *tracedGDB) {
+	handleFunc("/handle/exec/next", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkInferior(w) {
+			return
+		}
+
+		parms := gdblib.ExecNextParms{}
+
+		err := selectExecThread(mygdb, r)
+
+		if err == nil {
+			decoder := json.NewDecoder(r.Body)
+			err = decoder.Decode(&parms)
+		}
+
+		if err == nil {
+			err = mygdb.ExecNext(parms)
+		}
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/exec/step", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkInferior(w) {
+			return
+		}
+
+		parms := gdblib.ExecStepParms{}
+
+		err := selectExecThread(mygdb, r)
+
+		if err == nil {
+			decoder := json.NewDecoder(r.Body)
+			err = decoder.Decode(&parms)
+		}
+
+		if err == nil {
+			err = mygdb.ExecStep(parms)
+		}
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/exec/continue", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkInferior(w) {
+			return
+		}
+
+		parms := gdblib.ExecContinueParms{}
+
+		err := selectExecThread(mygdb, r)
+
+		if err == nil {
+			decoder := json.NewDecoder(r.Body)
+			err = decoder.Decode(&parms)
+		}
+
+		if err == nil {
+			err = mygdb.ExecContinue(parms)
+		}
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/exec/run", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkNotCore(w) {
+			return
+		}
+
+		parms := gdblib.ExecRunParms{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err == nil {
+			err = mygdb.ExecRun(parms)
+		}
+
+		// Running starts a new program under gdb's control
+		if err == nil {
+			atomic.StoreInt32(&detached, 0)
+		}
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/exec/args", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := gdblib.ExecArgsParms{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err == nil {
+			err = mygdb.ExecArgs(parms)
+		}
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/exec/interrupt", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkInferior(w) {
+			return
+		}
+
+		parms := gdblib.ExecInterruptParms{}
+
+		err := selectExecThread(mygdb, r)
+
+		if err == nil {
+			decoder := json.NewDecoder(r.Body)
+			err = decoder.Decode(&parms)
+		}
+
+		// With wait=true the response holds the location where the program
+		//  stopped instead of leaving it to the async event.
+		wait := r.URL.Query().Get("wait") == "true"
+
+		var watcher chan gdblib.AsyncResultRecord
+		if wait {
+			watcher = output.watchAsync()
+			defer output.unwatchAsync(watcher)
+		}
+
+		recentStops.expectInterrupt()
+		interruptErr := mygdb.ExecInterrupt(parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if interruptErr != nil {
+			// Without asynchronous mode gdb doesn't accept commands while the
+			//  program is running.
+			if !asyncEnabled(mygdb) {
+				w.WriteHeader(409)
+				w.Write([]byte("The program can't be paused because asynchronous mode is off for this target: " + interruptErr.Error()))
+				return
+			}
+
+			w.WriteHeader(400)
+			w.Write([]byte(interruptErr.Error()))
+			return
+		}
+
+		if !wait {
+			w.WriteHeader(200)
+			return
+		}
+
+		record, stopped := waitForStop(watcher, interruptStopTimeout)
+
+		if !stopped {
+			// The stop will still be reported on the websocket
+			w.WriteHeader(202)
+			return
+		}
+
+		result := struct {
+			Reason string
+			Thread interface{}
+			Frame  interface{}
+		}{}
+
+		result.Reason, _ = record.Result["reason"].(string)
+		result.Thread = record.Result["thread-id"]
+		result.Frame = record.Result["frame"]
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/exec/showargs", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := struct {
+			Args        string
+			Environment []string
+			Cwd         string
+		}{}
+
+		argsResult, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: "args"})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result.Args = argsResult.Value
+
+		envOutput, err := cliExec(mygdb, "show environment")
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result.Environment = []string{}
+		for _, line := range strings.Split(envOutput, "\n") {
+			if strings.Contains(line, "=") {
+				result.Environment = append(result.Environment, line)
+			}
+		}
+
+		// The program's working directory can only be set separately in newer
+		//  versions of gdb. Otherwise it is the same as gdb's.
+		cwdResult, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: "cwd"})
+		if err == nil {
+			result.Cwd = cwdResult.Value
+		}
+
+		if result.Cwd == "" {
+			pwdOutput, err := cliExec(mygdb, "pwd")
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			match := workingDirectoryPattern.FindStringSubmatch(pwdOutput)
+			if match != nil {
+				result.Cwd = match[1]
+			}
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/exec/stepstream", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkInferior(w) {
+			return
+		}
+
+		parms := struct {
+			Count int
+		}{}
+
+		err := selectExecThread(mygdb, r)
+
+		if err == nil {
+			decoder := json.NewDecoder(r.Body)
+			err = decoder.Decode(&parms)
+		}
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Count < 1 || parms.Count > maxStepStream {
+			w.WriteHeader(400)
+			w.Write([]byte("Count must be between 1 and " + strconv.Itoa(maxStepStream)))
+			return
+		}
+
+		watcher := output.watchAsync()
+		defer output.unwatchAsync(watcher)
+
+		result := struct {
+			Steps  int
+			Reason string
+		}{}
+
+		for result.Steps < parms.Count {
+			_, err = cliExec(mygdb, "stepi")
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			record, stopped := waitForStop(watcher, stepStreamTimeout)
+
+			if !stopped {
+				result.Reason = "timeout"
+				break
+			}
+
+			result.Steps++
+			result.Reason, _ = record.Result["reason"].(string)
+
+			frame, _ := record.Result["frame"].(map[string]interface{})
+			pc, _ := frame["addr"].(string)
+			line, _ := frame["line"].(string)
+
+			stepped := struct {
+				PC          string
+				Instruction string
+				Line        string
+			}{PC: pc, Line: line}
+
+			cliOutput, err := cliExec(mygdb, "x/i $pc")
+			if err == nil {
+				for _, instructionLine := range strings.Split(cliOutput, "\n") {
+					match := disassemblyPattern.FindStringSubmatch(instructionLine)
+					if match != nil {
+						stepped.Instruction = match[4]
+						break
+					}
+				}
+			}
+
+			output.publish(webSockResult{Type: "stepped", Data: stepped})
+
+			// Anything other than finishing the step (e.g. a breakpoint or a
+			//  signal) ends the stream so that it can be looked at.
+			if result.Reason != "end-stepping-range" {
+				break
+			}
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/exec/stepinto", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkInferior(w) {
+			return
+		}
+
+		parms := struct {
+			Target string
+		}{}
+
+		err := selectExecThread(mygdb, r)
+
+		if err == nil {
+			decoder := json.NewDecoder(r.Body)
+			err = decoder.Decode(&parms)
+		}
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Target == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No target function provided"))
+			return
+		}
+
+		cliOutput, err := cliExec(mygdb, "tbreak "+parms.Target)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		match := temporaryBreakpointPattern.FindStringSubmatch(cliOutput)
+
+		if match == nil {
+			w.WriteHeader(400)
+			w.Write([]byte(strings.TrimSpace(cliOutput)))
+			return
+		}
+
+		watcher := output.watchAsync()
+		defer output.unwatchAsync(watcher)
+
+		// Stepping over the line stops at the temporary breakpoint if the
+		//  target is called from it.
+		err = mygdb.ExecNext(gdblib.ExecNextParms{})
+
+		if err != nil {
+			cliExec(mygdb, "delete "+match[1])
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		record, stopped := waitForStop(watcher, stepIntoTimeout)
+
+		if !stopped {
+			// The breakpoint is left in place since the line hasn't finished
+			w.WriteHeader(202)
+			return
+		}
+
+		reason, _ := record.Result["reason"].(string)
+		number, _ := record.Result["bkptno"].(string)
+
+		if reason != "breakpoint-hit" || number != match[1] {
+			cliExec(mygdb, "delete "+match[1])
+
+			if reason == "end-stepping-range" {
+				w.WriteHeader(409)
+				w.Write([]byte(parms.Target + " wasn't called before the current line finished"))
+				return
+			}
+		}
+
+		result := struct {
+			Reason string
+			Thread interface{}
+			Frame  interface{}
+		}{reason, record.Result["thread-id"], record.Result["frame"]}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/exec/onexit", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			parms := struct {
+				Action string
+			}{}
+
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			if parms.Action != "stop" && parms.Action != "rerun" && parms.Action != "exit" {
+				w.WriteHeader(400)
+				w.Write([]byte("Action must be one of stop, rerun or exit"))
+				return
+			}
+
+			setOnExit(parms.Action)
+		}
+
+		result := struct {
+			Action string
+		}{getOnExit()}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/exec/fds", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pid, err := inferiorPid(mygdb)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// Gdb and the program are on this machine so the descriptors are read
+		//  straight from proc, which works with any version of gdb.
+		fdDir := filepath.Join("/proc", strconv.Itoa(pid), "fd")
+		entries, err := ioutil.ReadDir(fdDir)
+
+		if err != nil {
+			w.WriteHeader(501)
+			w.Write([]byte("The open files can't be read on this system: " + err.Error()))
+			return
+		}
+
+		result := []fileDescriptor{}
+		for _, entry := range entries {
+			fd, err := strconv.Atoi(entry.Name())
+			if err != nil {
+				continue
+			}
+
+			// The descriptor may have been closed since the listing
+			target, err := os.Readlink(filepath.Join(fdDir, entry.Name()))
+			if err != nil {
+				continue
+			}
+
+			result = append(result, fileDescriptor{fd, target})
+		}
+
+		sort.Sort(byFd(result))
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/exec/mappings", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cliOutput, err := cliExec(mygdb, "info proc mappings")
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		type mapping struct {
+			Start  string
+			End    string
+			Size   string
+			Offset string
+			Perms  string
+			Path   string
+		}
+
+		result := []mapping{}
+		for _, line := range strings.Split(cliOutput, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 4 {
+				continue
+			}
+
+			// Only the lines for the regions start with the four addresses
+			isRegion := true
+			for _, field := range fields[:4] {
+				if _, ok := parseAddress(field); !ok || !strings.HasPrefix(field, "0x") {
+					isRegion = false
+					break
+				}
+			}
+			if !isRegion {
+				continue
+			}
+
+			region := mapping{Start: fields[0], End: fields[1], Size: fields[2], Offset: fields[3]}
+			rest := fields[4:]
+			if len(rest) > 0 && mappingPermsPattern.MatchString(rest[0]) {
+				region.Perms = rest[0]
+				rest = rest[1:]
+			}
+			region.Path = strings.Join(rest, " ")
+
+			result = append(result, region)
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/exec/continueuntil", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkInferior(w) {
+			return
+		}
+
+		parms := struct {
+			Condition string
+			MaxHits   int
+		}{}
+
+		err := selectExecThread(mygdb, r)
+
+		if err == nil {
+			decoder := json.NewDecoder(r.Body)
+			err = decoder.Decode(&parms)
+		}
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Condition == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No condition provided"))
+			return
+		}
+
+		if parms.MaxHits == 0 {
+			parms.MaxHits = defaultContinueUntilHits
+		}
+
+		if parms.MaxHits < 1 || parms.MaxHits > maxContinueUntilHits {
+			w.WriteHeader(400)
+			w.Write([]byte("MaxHits must be between 1 and " + strconv.Itoa(maxContinueUntilHits)))
+			return
+		}
+
+		watcher := output.watchAsync()
+		defer output.unwatchAsync(watcher)
+
+		result := struct {
+			Hits   int
+			Reason string
+			Met    bool
+			Frame  interface{}
+		}{}
+
+		for result.Hits < parms.MaxHits {
+			output.settleStops()
+			err = mygdb.ExecContinue(gdblib.ExecContinueParms{})
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			record, stopped := waitForStop(watcher, continueUntilTimeout)
+
+			if !stopped {
+				// The program is still running, it can be interrupted to look
+				//  at where it has got to.
+				w.WriteHeader(202)
+				return
+			}
+
+			result.Reason, _ = record.Result["reason"].(string)
+			result.Frame = record.Result["frame"]
+
+			// Anything other than a breakpoint (e.g. a signal or the program
+			//  exiting) ends the loop so that it can be looked at.
+			if result.Reason != "breakpoint-hit" {
+				break
+			}
+
+			result.Hits++
+
+			value, err := mygdb.DataEvaluateExpression(gdblib.DataEvaluateExpressionParms{Expression: parms.Condition})
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			if valueIsTrue(value.Value) {
+				result.Met = true
+				break
+			}
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/exec/stophistory", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The locations are collected from the stopped records as they
+		//  arrive so this doesn't need gdb.
+		result := recentStops.get()
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/exec/location", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		frameResult, err := mygdb.StackInfoFrame()
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		frame := struct {
+			Frame backtraceFrame `json:"frame"`
+		}{}
+
+		err = remarshal(frameResult, &frame)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := struct {
+			Function string
+			Offset   int
+			File     string
+			Line     string
+			PC       string
+		}{frame.Frame.Func, 0, frame.Frame.File, frame.Frame.Line, frame.Frame.Addr}
+
+		// The symbol table gives the offset into the function, functions
+		//  without debug information are named from it too.
+		if addressPattern.MatchString(frame.Frame.Addr) {
+			cliOutput, err := cliExec(mygdb, "info symbol "+frame.Frame.Addr)
+			match := symbolOffsetPattern.FindStringSubmatch(strings.TrimSpace(cliOutput))
+
+			if err == nil && match != nil {
+				if result.Function == "" || result.Function == "??" {
+					result.Function = match[1]
+				}
+				result.Offset, _ = strconv.Atoi(match[2])
+			}
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/exec/realcwd", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dir := ""
+
+		// Gdb can read the directory of a remote program too, proc is only
+		//  read directly when it can't.
+		cliOutput, err := cliExec(mygdb, "info proc cwd")
+		if match := procCwdPattern.FindStringSubmatch(cliOutput); err == nil && match != nil {
+			dir = match[1]
+		} else {
+			pid, err := inferiorPid(mygdb)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			dir, err = os.Readlink(filepath.Join("/proc", strconv.Itoa(pid), "cwd"))
+
+			if err != nil {
+				w.WriteHeader(501)
+				w.Write([]byte("The working directory of the program can't be read on this system: " + err.Error()))
+				return
+			}
+		}
+
+		result := struct {
+			Cwd      string
+			RealPath string
+		}{dir, dir}
+
+		// The real path has any symbolic links resolved, it is only known
+		//  when the program is on this machine.
+		realPath, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			result.RealPath = realPath
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+}
+
+// A breakpoint as reported in the break-list result
+type breakpointInfo struct {
+	Number   string `json:"number"`
+	Type     string `json:"type"`
+	Disp     string `json:"disp"`
+	Enabled  string `json:"enabled"`
+	Addr     string `json:"addr"`
+	Func     string `json:"func"`
+	File     string `json:"file"`
+	Fullname string `json:"fullname"`
+	Line     string `json:"line"`
+	Times    string `json:"times"`
+	Cond     string `json:"cond"`
+	// Watchpoints have an expression instead of a location
+	What             string `json:"what"`
+	OriginalLocation string `json:"original-location"`
+}
+
+func breakList(mygdb *tracedGDB) ([]breakpointInfo, error) {
+	result, err := mygdb.BreakList()
+	if err != nil {
+		return nil, err
+	}
+
+	table := struct {
+		BreakPointTable struct {
+			Body []breakpointInfo `json:"body"`
+		}
+	}{}
+
+	err = remarshal(result, &table)
+	return table.BreakPointTable.Body, err
+}
+
+// Whether a breakpoint from a break-insert or break-list result has been
+// resolved to an address or is pending until its location is loaded (e.g.
+// in a shared library that hasn't been loaded yet)
+func breakpointStatus(bkpt map[string]interface{}) string {
+	_, pending := bkpt["pending"]
+	if pending || bkpt["addr"] == "<PENDING>" {
+		return "pending"
+	}
+	return "resolved"
+}
+
+// Parses an address printed by gdb (e.g. "0x0000000000400c00") so that it
+// can be compared regardless of the number of leading zeroes.
+func parseAddress(addr string) (uint64, bool) {
+	value, err := strconv.ParseUint(strings.TrimPrefix(addr, "0x"), 16, 64)
+	return value, err == nil
+}
+
+// Matches one of the locations of a breakpoint in the info breakpoints output
+// (e.g. "3.1    y   0x0000000000401136 in main.foo at /src/foo.go:12")
+var breakpointLocationPattern = regexp.MustCompile(`^([0-9]+\.[0-9]+)\s+([yn])\s+(0x[0-9a-fA-F]+)(?:\s+in (\S+) at (\S+):([0-9]+))?`)
+
+type breakpointLocation struct {
+	Number  string
+	Enabled bool
+	Addr    string
+	Func    string
+	File    string
+	Line    int
+}
+
+// Returns where a breakpoint was resolved to when it has more than one
+// location (e.g. an inlined function), otherwise there are none.
+func breakpointLocations(mygdb *tracedGDB, number string) ([]breakpointLocation, error) {
+	cliOutput, err := cliExec(mygdb, "info breakpoints "+number)
+	if err != nil {
+		return nil, err
+	}
+
+	locations := []breakpointLocation{}
+	for _, line := range strings.Split(cliOutput, "\n") {
+		match := breakpointLocationPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		lineNum, _ := strconv.Atoi(match[6])
+		locations = append(locations, breakpointLocation{match[1], match[2] == "y", match[3],
+			match[4], match[5], lineNum})
+	}
+
+	return locations, nil
+}
+
+// Matches a condition that a breakpoint was scoped to a thread with,
+// capturing the condition that it had before
+// (e.g. "(x > 3) && $_thread == 2")
+var threadScopePattern = regexp.MustCompile(`^(?:\((.*)\) && )?\$_thread == [0-9]+$`)
+
+// Notes that were written about why breakpoints were set keyed by the
+// breakpoint number
+var (
+	breakpointNotesMutex sync.Mutex
+	breakpointNotes      = make(map[int]string)
+)
+
+func getBreakpointNote(number int) string {
+	breakpointNotesMutex.Lock()
+	defer breakpointNotesMutex.Unlock()
+
+	return breakpointNotes[number]
+}
+
+// Sets the note of a breakpoint, an empty note removes it
+func setBreakpointNote(number int, note string) {
+	breakpointNotesMutex.Lock()
+	defer breakpointNotesMutex.Unlock()
+
+	if note == "" {
+		delete(breakpointNotes, number)
+	} else {
+		breakpointNotes[number] = note
+	}
+}
+
+// Matches the breakpoint number in the output of the dprintf command
+// (e.g. "Dprintf 4 at 0x400c10: file foo.go, line 12.")
+var dprintfCreatedPattern = regexp.MustCompile(`Dprintf ([0-9]+) at`)
+
+// The escapes of a C string that gdb's printf understands
+var cStringEscapes = map[rune]string{
+	'\\': `\\`, '"': `\"`, '\n': `\n`, '\t': `\t`, '\r': `\r`,
+	'\a': `\a`, '\b': `\b`, '\f': `\f`, '\v': `\v`,
+}
+
+// Quotes a format string for gdb's printf. Gdb doesn't know Go's \x and \u
+// escapes so any other character that can't be written as it is gets an
+// error.
+func quoteFormat(format string) (string, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for _, c := range format {
+		if escape, ok := cStringEscapes[c]; ok {
+			buf.WriteString(escape)
+		} else if unicode.IsPrint(c) {
+			buf.WriteRune(c)
+		} else {
+			return "", fmt.Errorf("The format can't contain the character %U", c)
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String(), nil
+}
+
+func addBreakpointHandlers(mygdb *tracedGDB) {
+	handleFunc("/handle/breakpoint/list", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		listResult, err := mygdb.BreakList()
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// The result is kept as it is apart from adding the status and the
+		//  note of each breakpoint.
+		result := make(map[string]interface{})
+
+		err = remarshal(listResult, &result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		for _, value := range result {
+			table, _ := value.(map[string]interface{})
+			body, _ := table["body"].([]interface{})
+			for _, entry := range body {
+				if bkpt, ok := entry.(map[string]interface{}); ok {
+					bkpt["status"] = breakpointStatus(bkpt)
+
+					number, _ := bkpt["number"].(string)
+					if num, err := strconv.Atoi(number); err == nil && getBreakpointNote(num) != "" {
+						bkpt["note"] = getBreakpointNote(num)
+					}
+				}
+			}
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/breakpoint/insert", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := gdblib.BreakInsertParms{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		insertResult, err := mygdb.BreakInsert(parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := make(map[string]interface{})
+		bkpt := struct {
+			Bkpt struct {
+				Number string `json:"number"`
+			} `json:"bkpt"`
+		}{}
+
+		err = remarshal(insertResult, &result)
+
+		if err == nil {
+			err = remarshal(insertResult, &bkpt)
+		}
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// All of the places that the breakpoint resolved to are added so
+		//  that the sub-locations can be toggled individually. The breakpoint
+		//  is there already so it is still returned if they can't be found.
+		locations, err := breakpointLocations(mygdb, bkpt.Bkpt.Number)
+
+		if err != nil {
+			log.Printf("Could not list the locations of breakpoint %v: %v\n", bkpt.Bkpt.Number, err)
+			locations = []breakpointLocation{}
+		}
+
+		result["locations"] = locations
+
+		if bkpt, ok := result["bkpt"].(map[string]interface{}); ok {
+			bkpt["status"] = breakpointStatus(bkpt)
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/breakpoint/enable", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := gdblib.BreakEnableParms{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		err = mygdb.BreakEnable(parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/breakpoint/disable", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := gdblib.BreakDisableParms{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		err = mygdb.BreakDisable(parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/breakpoint/insertaddress", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Address string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Address == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No address provided"))
+			return
+		}
+
+		// Locations that are addresses are prefixed with a '*'
+		result, err := mygdb.BreakInsert(gdblib.BreakInsertParms{Location: "*" + strings.TrimPrefix(parms.Address, "*")})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/breakpoint/dprintf", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			File   string
+			Line   int
+			Format string
+			Args   []string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.File == "" || parms.Line < 1 {
+			w.WriteHeader(400)
+			w.Write([]byte("A file and line must be provided"))
+			return
+		}
+
+		// Gdb prints the messages itself so that they show up on the console
+		//  rather than in the program's output.
+		err = mygdb.GdbSet(gdblib.GdbSetParms{Variable: "dprintf-style", Value: "gdb"})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		format, err := quoteFormat(parms.Format)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		command := "dprintf " + parms.File + ":" + strconv.Itoa(parms.Line) + "," + format
+		for _, arg := range parms.Args {
+			command += "," + arg
+		}
+
+		cliOutput, err := cliExec(mygdb, command)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		match := dprintfCreatedPattern.FindStringSubmatch(cliOutput)
+
+		if match == nil {
+			w.WriteHeader(400)
+			w.Write([]byte(strings.TrimSpace(cliOutput)))
+			return
+		}
+
+		result := struct {
+			Number string
+		}{match[1]}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/breakpoint/commands", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Number   int
+			Commands []string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// An empty list removes the commands from the breakpoint. The output
+		//  of the commands shows up on the console each time it is hit.
+		err = mygdb.BreakCommands(gdblib.BreakCommandsParms{Number: strconv.Itoa(parms.Number),
+			Commands: parms.Commands})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/breakpoint/locationtoggle", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Number   int
+			Location int
+			Enabled  bool
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		command := "disable "
+		if parms.Enabled {
+			command = "enable "
+		}
+
+		_, err = cliExec(mygdb, command+strconv.Itoa(parms.Number)+"."+strconv.Itoa(parms.Location))
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/breakpoint/thread", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Number int
+			Thread int
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		breakpoints, err := breakList(mygdb)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		var breakpoint *breakpointInfo
+		for idx := range breakpoints {
+			if breakpoints[idx].Number == strconv.Itoa(parms.Number) {
+				breakpoint = &breakpoints[idx]
+				break
+			}
+		}
+
+		if breakpoint == nil {
+			w.WriteHeader(404)
+			w.Write([]byte("No breakpoint number " + strconv.Itoa(parms.Number)))
+			return
+		}
+
+		// Gdb can't change the thread of an existing breakpoint so the
+		//  thread is checked in its condition instead. This keeps the
+		//  breakpoint's number and hit count. The condition is read back
+		//  each time since it may have been changed from elsewhere.
+		original := breakpoint.Cond
+		if match := threadScopePattern.FindStringSubmatch(original); match != nil {
+			original = match[1]
+		}
+
+		condition := original
+		if parms.Thread != 0 {
+			condition = "$_thread == " + strconv.Itoa(parms.Thread)
+			if original != "" {
+				condition = "(" + original + ") && " + condition
+			}
+		}
+
+		_, err = cliExec(mygdb, strings.TrimSpace("condition "+strconv.Itoa(parms.Number)+" "+condition))
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/breakpoint/insertmany", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := []gdblib.BreakInsertParms{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		type insertResult struct {
+			Result interface{} `json:",omitempty"`
+			Error  string      `json:",omitempty"`
+		}
+
+		// One breakpoint that can't be inserted (e.g. a function that
+		//  doesn't exist) doesn't stop the rest from being inserted.
+		result := []insertResult{}
+		for _, insertParms := range parms {
+			breakResult, err := mygdb.BreakInsert(insertParms)
+
+			if err != nil {
+				result = append(result, insertResult{Error: err.Error()})
+			} else {
+				result = append(result, insertResult{Result: breakResult})
+			}
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/breakpoint/validatecondition", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Condition string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Condition == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No condition provided"))
+			return
+		}
+
+		result := struct {
+			Valid bool
+			Type  string `json:",omitempty"`
+			Error string `json:",omitempty"`
+		}{}
+
+		// Whatis checks the expression in the selected frame without
+		//  running anything in it (e.g. an assignment or a function call)
+		//  that could change the program.
+		cliOutput, err := cliExec(mygdb, "whatis "+parms.Condition)
+
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Valid = true
+			result.Type = strings.TrimPrefix(strings.TrimSpace(cliOutput), "type = ")
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/breakpoint/distances", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		frameResult, err := mygdb.StackInfoFrame()
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		frame := struct {
+			Frame struct {
+				Func     string `json:"func"`
+				File     string `json:"file"`
+				Fullname string `json:"fullname"`
+				Line     string `json:"line"`
+			} `json:"frame"`
+		}{}
+
+		err = remarshal(frameResult, &frame)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		line, err := strconv.Atoi(frame.Frame.Line)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte("No line information for the current frame"))
+			return
+		}
+
+		breakpoints, err := breakList(mygdb)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		type distance struct {
+			Number string
+			Func   string
+			Line   int
+			// Whether the breakpoint is in the current function as well
+			//  as the current file
+			SameFunction bool
+			// One of before, at or after the current line
+			Position  string
+			LineDelta int
+		}
+
+		result := struct {
+			File        string
+			Line        int
+			Breakpoints []distance
+		}{frame.Frame.Fullname, line, []distance{}}
+
+		for _, breakpoint := range breakpoints {
+			sameFile := (breakpoint.Fullname != "" && breakpoint.Fullname == frame.Frame.Fullname) ||
+				(breakpoint.Fullname == "" && breakpoint.File != "" && breakpoint.File == frame.Frame.File)
+			bpLine, err := strconv.Atoi(breakpoint.Line)
+
+			// Breakpoints elsewhere (and watchpoints) aren't placed relative
+			//  to the current line.
+			if !sameFile || err != nil {
+				continue
+			}
+
+			position := "at"
+			if bpLine < line {
+				position = "before"
+			} else if bpLine > line {
+				position = "after"
+			}
+
+			result.Breakpoints = append(result.Breakpoints, distance{breakpoint.Number, breakpoint.Func, bpLine,
+				breakpoint.Func == frame.Frame.Func, position, bpLine - line})
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/breakpoint/note", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Number int
+			Note   string
+		}{}
+
+		if r.Method == "GET" {
+			number, err := strconv.Atoi(r.URL.Query().Get("number"))
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte("No breakpoint number provided"))
+				return
+			}
+
+			parms.Number = number
+			parms.Note = getBreakpointNote(number)
+		} else {
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			breakpoints, err := breakList(mygdb)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			found := false
+			for _, breakpoint := range breakpoints {
+				if breakpoint.Number == strconv.Itoa(parms.Number) {
+					found = true
+					break
+				}
+			}
+
+			// Removing the note of a breakpoint that has since been deleted
+			//  is allowed so that stale notes can be tidied up.
+			if !found && parms.Note != "" {
+				w.WriteHeader(404)
+				w.Write([]byte("No breakpoint number " + strconv.Itoa(parms.Number)))
+				return
+			}
+
+			setBreakpointNote(parms.Number, strings.TrimSpace(parms.Note))
+			parms.Note = getBreakpointNote(parms.Number)
+		}
+
+		resultBytes, err := json.Marshal(parms)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/breakpoint/files", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		breakpoints, err := breakList(mygdb)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		type breakpointFile struct {
+			File        string
+			Count       int
+			Breakpoints []string
+		}
+
+		// Watchpoints, catchpoints and breakpoints without debug information
+		//  have no file to list.
+		byFile := make(map[string]*breakpointFile)
+		files := []string{}
+		for _, breakpoint := range breakpoints {
+			file := breakpoint.Fullname
+			if file == "" {
+				file = breakpoint.File
+			}
+			if file == "" {
+				continue
+			}
+
+			if byFile[file] == nil {
+				byFile[file] = &breakpointFile{File: file, Breakpoints: []string{}}
+				files = append(files, file)
+			}
+			byFile[file].Count++
+			byFile[file].Breakpoints = append(byFile[file].Breakpoints, breakpoint.Number)
+		}
+
+		sort.Strings(files)
+
+		result := []breakpointFile{}
+		for _, file := range files {
+			result = append(result, *byFile[file])
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+}
+
+func addVariableHandlers(mygdb *tracedGDB) {
+	handleFunc("/handle/variable/create", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := gdblib.VarCreateParms{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result, err := mygdb.VarCreate(parms)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/variable/delete", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := gdblib.VarDeleteParms{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		err = mygdb.VarDelete(parms)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/variable/listchildren", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := gdblib.VarListChildrenParms{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result, err := mygdb.VarListChildren(parms)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+}
+
+// Maximum number of tracked expressions kept before they are all forgotten
+const maxTrackedValues = 10000
+
+// Last values of the tracked expressions keyed by the client's token and the
+// expression
+var (
+	trackedValuesMutex sync.Mutex
+	trackedValues      = make(map[string]string)
+)
+
+// Matches a variable declaration in the info variables output with the line
+// number that newer versions of gdb put in front of it
+// (e.g. "12:	int main.count;" or "static char buf[10];")
+var variableDeclarationPattern = regexp.MustCompile(`^(?:([0-9]+):\s*)?(.*?)([^\s*&]+?)((?:\[[^\]]*\])*);$`)
+
+// Matches a symbol without debugging information in the info variables output
+// (e.g. "0x0000000000601040  __data_start")
+var nonDebuggingSymbolPattern = regexp.MustCompile(`^(0x[0-9a-fA-F]+)\s+(\S+)$`)
+
+// Matches a line of the info display output (e.g. "1:   y  /x count")
+var displayInfoPattern = regexp.MustCompile(`^([0-9]+):\s+([yn])\s+(.*)$`)
+
+// Matches an address found by the find command
+// (e.g. "0x601040 <buf+16>")
+var foundAddressPattern = regexp.MustCompile(`^(0x[0-9a-fA-F]+)`)
+
+// Default and largest number of matches returned by a memory search
+const defaultFindResults = 100
+const maxFindResults = 10000
+
+// Converts the pattern of a memory search into the values of the find
+// command. A quoted string is searched for as it is while anything else is
+// taken as hex bytes (e.g. "de ad be ef" or "0xdeadbeef").
+func findPatternValues(pattern string) (string, error) {
+	if len(pattern) >= 2 && strings.HasPrefix(pattern, "\"") && strings.HasSuffix(pattern, "\"") {
+		return pattern, nil
+	}
+
+	hexBytes := strings.TrimPrefix(strings.Join(strings.Fields(pattern), ""), "0x")
+	data, err := hex.DecodeString(hexBytes)
+	if err != nil || len(data) == 0 {
+		return "", errors.New("Pattern must be hex bytes or a quoted string")
+	}
+
+	values := []string{}
+	for _, value := range data {
+		values = append(values, fmt.Sprintf("0x%02x", value))
+	}
+	return strings.Join(values, ", "), nil
+}
+
+type mixedInstruction struct {
+	Address     string
+	Offset      string
+	Instruction string
+	IsCurrentPC bool
+}
+
+type mixedSourceLine struct {
+	File         string
+	Line         int
+	Source       string
+	Instructions []mixedInstruction
+}
+
+// Disassembles the function at the location (the current one if it is empty)
+// with the source lines that the instructions came from.
+func disassembleMixed(mygdb *tracedGDB, location string) ([]*mixedSourceLine, error) {
+	// The /s modifier is only in newer versions of gdb, older ones have
+	//  /m which lists the source lines in order instead.
+	cliOutput, err := cliExec(mygdb, strings.TrimSpace("disassemble /s "+location))
+
+	if err != nil {
+		cliOutput, err = cliExec(mygdb, strings.TrimSpace("disassemble /m "+location))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*mixedSourceLine{}
+	file := ""
+	var current *mixedSourceLine
+	for _, line := range strings.Split(cliOutput, "\n") {
+		if match := disassemblyPattern.FindStringSubmatch(line); match != nil {
+			// Instructions that come before any source line
+			if current == nil {
+				current = &mixedSourceLine{File: file, Instructions: []mixedInstruction{}}
+				result = append(result, current)
+			}
+
+			current.Instructions = append(current.Instructions,
+				mixedInstruction{match[2], match[3], match[4], match[1] == "=>"})
+			continue
+		}
+
+		if match := mixedSourcePattern.FindStringSubmatch(line); match != nil {
+			lineNum, _ := strconv.Atoi(match[1])
+			current = &mixedSourceLine{file, lineNum, match[2], []mixedInstruction{}}
+			result = append(result, current)
+			continue
+		}
+
+		if strings.HasPrefix(line, "Dump of") || strings.HasPrefix(line, "End of") {
+			continue
+		}
+
+		if match := mixedFilePattern.FindStringSubmatch(line); match != nil {
+			file = match[1]
+		}
+	}
+
+	return result, nil
+}
+
+// Output formats that an evaluated value can be shown in: hexadecimal,
+// decimal, binary, octal, character, floating point and address
+const evaluateFormats = "xdtocfa"
+
+func addDataHandlers(mygdb *tracedGDB) {
+	handleFunc("/handle/data/display/insert", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Expression string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Expression == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No expression provided"))
+			return
+		}
+
+		cliOutput, err := cliExec(mygdb, "display "+parms.Expression)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// Gdb shows the new display's value right away when it can
+		match := displayValuePattern.FindStringSubmatch(strings.TrimSpace(cliOutput))
+
+		result := struct {
+			Number int
+			Value  string
+		}{}
+
+		if match != nil {
+			result.Number, _ = strconv.Atoi(match[1])
+			result.Value = match[3]
+		} else {
+			displayList, err := cliExec(mygdb, "info display")
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// The new display is the last one in the list
+			for _, line := range strings.Split(displayList, "\n") {
+				infoMatch := displayInfoPattern.FindStringSubmatch(line)
+				if infoMatch != nil {
+					result.Number, _ = strconv.Atoi(infoMatch[1])
+				}
+			}
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/display/list", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cliOutput, err := cliExec(mygdb, "info display")
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		type display struct {
+			Number     int
+			Enabled    bool
+			Expression string
+		}
+
+		result := []display{}
+		for _, line := range strings.Split(cliOutput, "\n") {
+			match := displayInfoPattern.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+
+			number, _ := strconv.Atoi(match[1])
+			result = append(result, display{number, match[2] == "y", strings.TrimSpace(match[3])})
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/display/history", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		number, err := strconv.Atoi(r.URL.Query().Get("number"))
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte("No display number provided"))
+			return
+		}
+
+		// Values are collected as gdb shows the displays each time the
+		//  program stops.
+		result, ok := displays.get(number)
+
+		if !ok {
+			w.WriteHeader(404)
+			w.Write([]byte("No values recorded for display " + strconv.Itoa(number)))
+			return
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/display/remove", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Number int
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Number < 1 {
+			w.WriteHeader(400)
+			w.Write([]byte("No display number provided"))
+			return
+		}
+
+		_, err = cliExec(mygdb, "undisplay "+strconv.Itoa(parms.Number))
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		displays.remove(parms.Number)
+
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/data/display/removeall", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Gdb would normally ask for confirmation here but it answers the
+		//  query itself since it is not attached to a terminal.
+		_, err := cliExec(mygdb, "undisplay")
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		displays.removeAll()
+
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/data/disassemble", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cliOutput, err := cliExec(mygdb, "disassemble")
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		frameResult, err := mygdb.StackInfoFrame()
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		frame := struct {
+			Frame struct {
+				Addr string `json:"addr"`
+			} `json:"frame"`
+		}{}
+
+		err = remarshal(frameResult, &frame)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		breakpoints, err := breakList(mygdb)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// Addresses are compared numerically since gdb doesn't pad them
+		//  the same way everywhere.
+		breakpointAddrs := make(map[uint64]bool)
+		for _, breakpoint := range breakpoints {
+			addr, ok := parseAddress(breakpoint.Addr)
+			if ok {
+				breakpointAddrs[addr] = true
+			}
+		}
+		pc, _ := parseAddress(frame.Frame.Addr)
+
+		type instruction struct {
+			Address       string
+			Offset        string
+			Instruction   string
+			IsCurrentPC   bool
+			HasBreakpoint bool
+		}
+
+		result := []instruction{}
+		for _, line := range strings.Split(cliOutput, "\n") {
+			match := disassemblyPattern.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+
+			addr, _ := parseAddress(match[2])
+			result = append(result, instruction{match[2], match[3], match[4],
+				addr == pc, breakpointAddrs[addr]})
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/call", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkAllowWrite(w) || !checkNotCore(w) {
+			return
+		}
+
+		parms := gdblib.DataEvaluateExpressionParms{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// A signal in the called function unwinds back to where the program
+		//  was stopped instead of leaving it stopped inside of the call. The
+		//  setting is put back afterwards so that it only applies here.
+		unwind, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: "unwindonsignal"})
+
+		if err == nil {
+			err = mygdb.GdbSet(gdblib.GdbSetParms{Variable: "unwindonsignal", Value: "on"})
+		}
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result, err := mygdb.DataEvaluateExpression(parms)
+		mygdb.GdbSet(gdblib.GdbSetParms{Variable: "unwindonsignal", Value: unwind.Value})
+
+		if err != nil {
+			// Gdb gives up on the call if it hits a breakpoint or a signal
+			//  and explains what happened in the error.
+			if strings.Contains(err.Error(), "function called from GDB") {
+				w.WriteHeader(409)
+			} else {
+				w.WriteHeader(400)
+			}
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/evaluate", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Expression string
+			// Record the value in gdb's value history so that it can be
+			//  referred to later as $N
+			History bool
+			// Output format letter for the value (e.g. x for hexadecimal)
+			Format string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Format != "" && (len(parms.Format) != 1 || !strings.Contains(evaluateFormats, parms.Format)) {
+			w.WriteHeader(400)
+			w.Write([]byte("Format must be one of " + strings.Join(strings.Split(evaluateFormats, ""), ", ")))
+			return
+		}
+
+		formatSuffix := ""
+		if parms.Format != "" {
+			formatSuffix = "/" + parms.Format
+		}
+
+		var result interface{}
+
+		if parms.History {
+			// Only the print command adds to the value history
+			cliOutput, err := cliExec(mygdb, "print"+formatSuffix+" "+parms.Expression)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			historyResult := struct {
+				Value   string `json:"value"`
+				History int    `json:"history"`
+			}{}
+			for number, value := range parseValueHistory(cliOutput) {
+				historyResult.History = number
+				historyResult.Value = value
+			}
+			result = historyResult
+		} else if parms.Format != "" {
+			// The evaluate command of the MI has no format so the output
+			//  command is used, which leaves the value history alone.
+			cliOutput, err := cliExec(mygdb, "output"+formatSuffix+" "+parms.Expression)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			result = struct {
+				Value string `json:"value"`
+			}{strings.TrimSpace(cliOutput)}
+		} else {
+			result, err = mygdb.DataEvaluateExpression(gdblib.DataEvaluateExpressionParms{Expression: parms.Expression})
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/valuehistory", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			// Show the values around this one instead of the most recent
+			Number int
+		}{}
+
+		if r.Method != "GET" {
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		}
+
+		command := "show values"
+		if parms.Number > 0 {
+			command += " " + strconv.Itoa(parms.Number)
+		}
+
+		cliOutput, err := cliExec(mygdb, command)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		type historyValue struct {
+			Number int
+			Value  string
+		}
+
+		values := parseValueHistory(cliOutput)
+		numbers := []int{}
+		for number := range values {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+
+		result := []historyValue{}
+		for _, number := range numbers {
+			result = append(result, historyValue{number, values[number]})
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/gomap", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Expression string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Expression == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No expression provided"))
+			return
+		}
+
+		entries, count, err := goMapEntries(mygdb, parms.Expression)
+
+		if err != nil {
+			// The map layout is tied to the version of the Go runtime
+			if strings.HasPrefix(err.Error(), errUnsupportedGoVersion.Error()) {
+				w.WriteHeader(501)
+			} else {
+				w.WriteHeader(400)
+			}
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := struct {
+			Count   int
+			Entries []goMapEntry
+		}{count, entries}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/globals", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Regex string
+			Name  string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		var result interface{}
+
+		if parms.Name != "" {
+			// Go package names contain characters (e.g. "net/http.Client")
+			//  that gdb only accepts in a quoted symbol.
+			expression := parms.Name
+			if strings.ContainsAny(expression, "./") {
+				expression = "'" + expression + "'"
+			}
+
+			evalResult, err := mygdb.DataEvaluateExpression(gdblib.DataEvaluateExpressionParms{Expression: expression})
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			result = struct {
+				Name  string
+				Value string
+			}{parms.Name, evalResult.Value}
+		} else {
+			cliOutput, err := cliExec(mygdb, strings.TrimSpace("info variables "+parms.Regex))
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			type global struct {
+				Name    string
+				Type    string
+				File    string
+				Line    int
+				Address string
+			}
+
+			globals := []global{}
+			file := ""
+			nonDebugging := false
+			for _, line := range strings.Split(cliOutput, "\n") {
+				line = strings.TrimSpace(line)
+
+				if strings.HasPrefix(line, "File ") && strings.HasSuffix(line, ":") {
+					file = strings.TrimSuffix(strings.TrimPrefix(line, "File "), ":")
+					continue
+				}
+				if line == "Non-debugging symbols:" {
+					nonDebugging = true
+					continue
+				}
+
+				if nonDebugging {
+					match := nonDebuggingSymbolPattern.FindStringSubmatch(line)
+					if match != nil {
+						globals = append(globals, global{Name: match[2], Address: match[1]})
+					}
+					continue
+				}
+
+				match := variableDeclarationPattern.FindStringSubmatch(line)
+				if match == nil {
+					continue
+				}
+
+				lineNum, _ := strconv.Atoi(match[1])
+				globals = append(globals, global{Name: match[3],
+					Type: strings.TrimSpace(match[2]) + match[4], File: file, Line: lineNum})
+			}
+
+			result = globals
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/restore", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkAllowWrite(w) {
+			return
+		}
+
+		parms := struct {
+			File    string
+			Address string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Address == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No address provided"))
+			return
+		}
+
+		path, err := sourceFilePath(parms.File)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// Gdb has no way to quote the file name of the restore command
+		if strings.ContainsAny(path, " \t") {
+			w.WriteHeader(400)
+			w.Write([]byte("The file path can't contain spaces"))
+			return
+		}
+
+		info, err := os.Stat(path)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// With a binary file the bias is the address that the first byte
+		//  of the file is written to.
+		cliOutput, err := cliExec(mygdb, "restore "+path+" binary "+parms.Address)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := struct {
+			File   string
+			Count  int64
+			Output string
+		}{path, info.Size(), strings.TrimSpace(cliOutput)}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/mixed", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := disassembleMixed(mygdb, "")
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/evaluatetracked", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Expression string
+			Token      string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Expression == "" || parms.Token == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("An expression and a token must be provided"))
+			return
+		}
+
+		evalResult, err := mygdb.DataEvaluateExpression(gdblib.DataEvaluateExpressionParms{Expression: parms.Expression})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := struct {
+			Value    string
+			Changed  bool
+			Previous string
+		}{Value: evalResult.Value}
+
+		key := parms.Token + "\x00" + parms.Expression
+
+		trackedValuesMutex.Lock()
+		previous, tracked := trackedValues[key]
+		if !tracked && len(trackedValues) >= maxTrackedValues {
+			trackedValues = make(map[string]string)
+		}
+		trackedValues[key] = evalResult.Value
+		trackedValuesMutex.Unlock()
+
+		// The first evaluation for a token has nothing to compare with
+		if tracked {
+			result.Changed = previous != evalResult.Value
+			result.Previous = previous
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/hexdump", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Address string
+			Count   int
+			Width   int
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Width == 0 {
+			parms.Width = defaultHexdumpWidth
+		}
+
+		if parms.Width < 1 || parms.Width > maxHexdumpWidth {
+			w.WriteHeader(400)
+			w.Write([]byte("Width must be between 1 and " + strconv.Itoa(maxHexdumpWidth)))
+			return
+		}
+
+		start, memory, err := readMemory(mygdb, parms.Address, parms.Count)
+
+		// Running into memory that can't be read part way through still
+		//  gives a dump with the rest of it marked as unreadable.
+		if err != nil && len(memory) == 0 {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := hexdumpRows(start, memory, parms.Count, parms.Width)
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/locate", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Expression string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Expression == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No expression provided"))
+			return
+		}
+
+		address, err := evaluateAddress(mygdb, "&("+parms.Expression+")")
+
+		if err != nil {
+			// Values that live in a register or that are computed (e.g. a+1)
+			//  have no address to show as memory.
+			if strings.Contains(err.Error(), "in register") || strings.Contains(err.Error(), "not an lvalue") {
+				w.WriteHeader(409)
+				w.Write([]byte(parms.Expression + " has no address in memory: " + err.Error()))
+			} else {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+			}
+			return
+		}
+
+		size, err := evaluateInt(mygdb, "sizeof("+parms.Expression+")")
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		cliOutput, err := cliExec(mygdb, "whatis "+parms.Expression)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := struct {
+			Address string
+			Size    int
+			Type    string
+		}{fmt.Sprintf("0x%x", address), size, strings.TrimPrefix(strings.TrimSpace(cliOutput), "type = ")}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/findmemory", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Start      string
+			End        string
+			Pattern    string
+			MaxResults int
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Start == "" || parms.End == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No start or end provided"))
+			return
+		}
+
+		if parms.MaxResults == 0 {
+			parms.MaxResults = defaultFindResults
+		}
+
+		if parms.MaxResults < 1 || parms.MaxResults > maxFindResults {
+			w.WriteHeader(400)
+			w.Write([]byte("MaxResults must be between 1 and " + strconv.Itoa(maxFindResults)))
+			return
+		}
+
+		values, err := findPatternValues(parms.Pattern)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// Hex bytes are searched for one byte at a time so that gdb doesn't
+		//  widen them to the size of an int.
+		size := "b"
+		if strings.HasPrefix(values, "\"") {
+			size = ""
+		}
+
+		cliOutput, err := cliExec(mygdb, "find /"+size+strconv.Itoa(parms.MaxResults)+" "+
+			parms.Start+", "+parms.End+", "+values)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := []string{}
+		for _, line := range strings.Split(cliOutput, "\n") {
+			match := foundAddressPattern.FindStringSubmatch(strings.TrimSpace(line))
+			if match != nil {
+				result = append(result, match[1])
+			}
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/evaluateallthreads", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Expression string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Expression == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No expression provided"))
+			return
+		}
+
+		idsResult, err := mygdb.ThreadListIds()
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		ids := struct {
+			ThreadIds []string `json:"thread-ids"`
+		}{}
+
+		err = remarshal(idsResult, &ids)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		type threadValue struct {
+			Value string `json:",omitempty"`
+			Error string `json:",omitempty"`
+		}
+
+		// The expression is evaluated in the innermost frame of each thread,
+		//  a thread where it can't be (e.g. a variable that isn't in scope)
+		//  gets the error instead.
+		result := make(map[string]threadValue)
+
+		for _, threadId := range ids.ThreadIds {
+			value, evalErr := mygdb.DataEvaluateExpression(gdblib.DataEvaluateExpressionParms{Thread: threadId,
+				Frame: "0", Expression: parms.Expression})
+			if evalErr != nil {
+				result[threadId] = threadValue{Error: evalErr.Error()}
+			} else {
+				result[threadId] = threadValue{Value: value.Value}
+			}
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/disassemblesymbol", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Symbol string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Symbol == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No symbol provided"))
+			return
+		}
+
+		if strings.Contains(parms.Symbol, "'") {
+			w.WriteHeader(400)
+			w.Write([]byte("The symbol can't contain quotes"))
+			return
+		}
+
+		// Quoting keeps gdb from reading the parts of Go names (e.g.
+		//  main.(*T).Method) as an expression.
+		lines, err := disassembleMixed(mygdb, "'"+parms.Symbol+"'")
+
+		if err != nil {
+			if strings.Contains(err.Error(), "No symbol") {
+				w.WriteHeader(404)
+			} else {
+				w.WriteHeader(400)
+			}
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := struct {
+			Symbol string
+			Start  string
+			End    string
+			Lines  []*mixedSourceLine
+		}{Symbol: parms.Symbol, Lines: lines}
+
+		// The range runs from the first instruction to the start of the last
+		//  one. They are found by address since /m lists them in source order.
+		var start, end uint64
+		for _, line := range lines {
+			for _, instruction := range line.Instructions {
+				address, ok := parseAddress(instruction.Address)
+				if !ok {
+					continue
+				}
+				if start == 0 || address < start {
+					start = address
+				}
+				if address > end {
+					end = address
+				}
+			}
+		}
+
+		if start != 0 {
+			result.Start = fmt.Sprintf("0x%x", start)
+			result.End = fmt.Sprintf("0x%x", end)
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/structlayout", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Type string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Type == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No type provided"))
+			return
+		}
+
+		layout, err := layoutStruct(mygdb, parms.Type)
+
+		if err == errNotStruct {
+			w.WriteHeader(409)
+			w.Write([]byte(parms.Type + ": " + err.Error()))
+			return
+		}
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		resultBytes, err := json.Marshal(layout)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/poll", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			parms := pollSettings{}
+
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// No expression stops the polling
+			if parms.Expression == "" {
+				stopPoll()
+			} else {
+				if !checkInferior(w) {
+					return
+				}
+
+				interval := defaultPollInterval
+				if parms.Interval != "" {
+					interval, err = time.ParseDuration(parms.Interval)
+
+					if err != nil {
+						w.WriteHeader(400)
+						w.Write([]byte(err.Error()))
+						return
+					}
+				}
+
+				if interval < minPollInterval {
+					w.WriteHeader(400)
+					w.Write([]byte("Interval must be at least " + minPollInterval.String()))
+					return
+				}
+
+				parms.Interval = interval.String()
+				startPoll(mygdb, parms, interval)
+			}
+		}
+
+		settings, active := getPoll()
+
+		result := struct {
+			Active     bool
+			Expression string
+			Condition  string
+			Interval   string
+		}{active, settings.Expression, settings.Condition, settings.Interval}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+}
+
+// Matches a query that gdb asks before a command goes ahead
+// (e.g. "Delete all breakpoints? (y or n) ")
+var queryPattern = regexp.MustCompile(`(?m)^(.*\((?:y or n|y or \[n\]|\[y\] or n)\))`)
+
+// Returns the queries in the output of a CLI command. Gdb answers them itself
+// since its input isn't a terminal in MI mode.
+func answeredQueries(cliOutput string) []string {
+	answered := []string{}
+	for _, match := range queryPattern.FindAllStringSubmatch(cliOutput, -1) {
+		answered = append(answered, strings.TrimSpace(match[1]))
+	}
+	return answered
+}
+
+// Number of frames that gdb unwinds at most until the limit is changed
+const defaultBacktraceLimit = 10000
+
+// Matches the description of a remote target in the info target output
+// (e.g. "Extended remote serial target in gdb-specific protocol:")
+var remoteTargetPattern = regexp.MustCompile(`(?i)remote serial target`)
+
+func addGdbHandlers(mygdb *tracedGDB) {
+	// The transcript handler is registered directly so that reading the
+	//  transcript doesn't add to it.
+	handlerPaths = append(handlerPaths, "/handle/gdb/transcript")
+	http.HandleFunc("/handle/gdb/transcript", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			sessionTranscript.clear()
+			w.WriteHeader(200)
+			return
+		}
+
+		resultBytes, err := json.Marshal(sessionTranscript.list())
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/capabilities", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := struct {
+			Handlers    []string
+			Features    map[string]bool
+			GdbFeatures []string
+		}{}
+
+		result.Handlers = append([]string{}, handlerPaths...)
+		sort.Strings(result.Handlers)
+
+		// Optional features that depend on how godbg was launched
+		result.Features = map[string]bool{
+			"remote-http": hostName != loopbackHost,
+			"base-path":   *basePath != "",
+			"non-stop":    *nonStop,
+			"write":       *allowWrite,
+			"core":        *coreFile != "",
+		}
+
+		// Features of the target that gdb is connected to. Gdb can step
+		//  backwards when the target reports it or when it is recording.
+		targetFeatures, err := mygdb.ListTargetFeatures()
+		if err == nil {
+			for _, feature := range targetFeatures.Features {
+				if strings.Contains(feature, "reverse") {
+					result.Features["reverse-debugging"] = true
+				}
+			}
+		}
+		if !result.Features["reverse-debugging"] {
+			cliOutput, err := cliExec(mygdb, "info record")
+			result.Features["reverse-debugging"] = err == nil && recordTargetPattern.MatchString(cliOutput)
+		}
+
+		cliOutput, err := cliExec(mygdb, "info target")
+		result.Features["remote-target"] = err == nil && remoteTargetPattern.MatchString(cliOutput)
+
+		// Older versions of gdb don't support listing their features
+		gdbFeatures, err := mygdb.ListFeatures()
+		if err == nil {
+			result.GdbFeatures = gdbFeatures.Features
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/cli", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Command string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Command == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No command provided"))
+			return
+		}
+
+		result := struct {
+			Output string
+			// Queries that gdb answered itself
+			Answered []string
+		}{}
+
+		result.Output, err = cliExec(mygdb, parms.Command)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result.Answered = answeredQueries(result.Output)
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/stringlength", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Length int
+		}{}
+
+		// Gdb before 14 has no separate limit for strings, they are cut off
+		//  at the print elements limit along with the arrays.
+		setting := "print characters"
+		_, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: setting})
+		if err != nil {
+			setting = "print elements"
+		}
+
+		if r.Method != "GET" {
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			if parms.Length < 0 {
+				w.WriteHeader(400)
+				w.Write([]byte("Length must not be negative"))
+				return
+			}
+
+			// Zero means unlimited for gdb
+			err = mygdb.GdbSet(gdblib.GdbSetParms{Variable: setting, Value: strconv.Itoa(parms.Length)})
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		}
+
+		result, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: setting})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// By default the characters limit follows the elements one
+		value := result.Value
+		if value == "elements" {
+			result, err = mygdb.GdbShow(gdblib.GdbShowParms{Variable: "print elements"})
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			value = result.Value
+		}
+
+		if value != "unlimited" {
+			parms.Length, err = strconv.Atoi(value)
+
+			if err != nil {
+				w.WriteHeader(500)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		} else {
+			parms.Length = 0
+		}
+
+		response := struct {
+			Length  int
+			Setting string
+		}{parms.Length, setting}
+
+		resultBytes, err := json.Marshal(response)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/printelements", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Count int
+		}{}
+
+		if r.Method != "GET" {
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			if parms.Count < 0 {
+				w.WriteHeader(400)
+				w.Write([]byte("Count must not be negative"))
+				return
+			}
+
+			// Zero means unlimited for gdb
+			err = mygdb.GdbSet(gdblib.GdbSetParms{Variable: "print elements", Value: strconv.Itoa(parms.Count)})
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		}
+
+		result, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: "print elements"})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if result.Value != "unlimited" {
+			parms.Count, err = strconv.Atoi(result.Value)
+
+			if err != nil {
+				w.WriteHeader(500)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		} else {
+			parms.Count = 0
+		}
+
+		resultBytes, err := json.Marshal(parms)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/nonstop", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The program is started along with godbg and gdb refuses to change
+		//  the mode once it is running, so the mode is chosen with -non-stop.
+		if r.Method != "GET" {
+			w.WriteHeader(409)
+			w.Write([]byte("Non-stop mode can only be chosen when godbg starts, restart it with or without -non-stop"))
+			return
+		}
+
+		result, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: "non-stop"})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		parms := struct {
+			Enabled bool
+		}{result.Value == "on"}
+
+		resultBytes, err := json.Marshal(parms)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/charset", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Host   string
+			Target string
+		}{}
+
+		if r.Method != "GET" {
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Only the character sets that are provided are changed
+			if parms.Host != "" {
+				err = mygdb.GdbSet(gdblib.GdbSetParms{Variable: "host-charset", Value: parms.Host})
+			}
+			if err == nil && parms.Target != "" {
+				err = mygdb.GdbSet(gdblib.GdbSetParms{Variable: "target-charset", Value: parms.Target})
+			}
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		}
+
+		hostResult, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: "host-charset"})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		targetResult, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: "target-charset"})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		parms.Host = hostResult.Value
+		parms.Target = targetResult.Value
+
+		resultBytes, err := json.Marshal(parms)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/features", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		features, err := mygdb.ListFeatures()
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		targetFeatures, err := mygdb.ListTargetFeatures()
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := struct {
+			Features       []string
+			TargetFeatures []string
+		}{features.Features, targetFeatures.Features}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/async", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Enabled bool
+		}{}
+
+		if r.Method != "GET" {
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			value := "off"
+			if parms.Enabled {
+				value = "on"
+			}
+
+			// Gdb refuses to change the mode once the program is running
+			err = mygdb.GdbSet(gdblib.GdbSetParms{Variable: asyncSetting(mygdb), Value: value})
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		}
+
+		parms.Enabled = asyncEnabled(mygdb)
+
+		resultBytes, err := json.Marshal(parms)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/stopformat", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			parms := struct {
+				Mode string
+			}{}
+
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			if parms.Mode != "none" && parms.Mode != "source" && parms.Mode != "disassembly" && parms.Mode != "both" {
+				w.WriteHeader(400)
+				w.Write([]byte("Mode must be one of none, source, disassembly or both"))
+				return
+			}
+
+			setStopFormat(parms.Mode)
+		}
+
+		result := struct {
+			Mode string
+		}{getStopFormat()}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/sessionconfig", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			result, err := exportSessionConfig(mygdb)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			resultBytes, err := json.MarshalIndent(result, "", "  ")
+
+			if err != nil {
+				w.WriteHeader(500)
+				w.Write([]byte(err.Error()))
+			} else {
+				w.WriteHeader(200)
+				w.Write(resultBytes)
+			}
+			return
+		}
+
+		// Applying a configuration runs the commands of its breakpoints
+		if !checkAllowWrite(w) {
+			return
+		}
+
+		parms := sessionConfig{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := struct {
+			Problems []string
+		}{checkSessionConfig(&parms)}
+
+		// The current setup is only replaced by a configuration that can be
+		//  applied as a whole.
+		if len(result.Problems) > 0 {
+			resultBytes, _ := json.Marshal(result)
+			w.WriteHeader(400)
+			w.Write(resultBytes)
+			return
+		}
+
+		result.Problems = applySessionConfig(mygdb, &parms)
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/result", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("id")
+
+		if id == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No request id provided"))
+			return
+		}
+
+		result, ok := requestResults.get(id)
+
+		if !ok {
+			w.WriteHeader(404)
+			w.Write([]byte("No request has been handled with that id"))
+			return
+		}
+
+		writeCachedResult(w, result)
+	}))
+
+	handleFunc("/handle/gdb/stoponsolib", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Enabled bool
+		}{}
+
+		if r.Method != "GET" {
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			value := "0"
+			if parms.Enabled {
+				value = "1"
+			}
+
+			err = mygdb.GdbSet(gdblib.GdbSetParms{Variable: "stop-on-solib-events", Value: value})
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		}
+
+		result, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: "stop-on-solib-events"})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		parms.Enabled = result.Value != "0"
+
+		resultBytes, err := json.Marshal(parms)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/metrics", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The time that gdb takes for each kind of command is kept apart
+		//  from the time that handlers take so that slowness in gdb can be
+		//  told from slowness anywhere else.
+		result := struct {
+			Commands []latencySummary
+			Handlers []latencySummary
+		}{commandLatency.summary(), handlerLatency.summary()}
+
+		if r.URL.Query().Get("format") == "prometheus" {
+			writePrometheusMetrics(w, result.Commands, result.Handlers)
+			return
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/printaddress", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		settings := []struct {
+			Variable string
+			Value    *bool
+		}{{Variable: "print address"}, {Variable: "print symbol"}}
+
+		if r.Method != "GET" {
+			parms := struct {
+				Address *bool
+				Symbol  *bool
+			}{}
+
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// Only the flags that are given are changed
+			settings[0].Value = parms.Address
+			settings[1].Value = parms.Symbol
+
+			for _, setting := range settings {
+				if setting.Value == nil {
+					continue
+				}
+
+				value := "off"
+				if *setting.Value {
+					value = "on"
+				}
+
+				err = mygdb.GdbSet(gdblib.GdbSetParms{Variable: setting.Variable, Value: value})
+
+				if err != nil {
+					w.WriteHeader(400)
+					w.Write([]byte(err.Error()))
+					return
+				}
+			}
+		}
+
+		result := struct {
+			Address bool
+			Symbol  bool
+		}{}
+
+		addressResult, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: "print address"})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		symbolResult, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: "print symbol"})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result.Address = addressResult.Value == "on"
+		result.Symbol = symbolResult.Value == "on"
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/stepmode", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Enabled bool
+		}{}
+
+		if r.Method != "GET" {
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			// With step-mode on stepping stops in functions without line
+			//  information (e.g. assembly in the Go runtime).
+			value := "off"
+			if parms.Enabled {
+				value = "on"
+			}
+
+			err = mygdb.GdbSet(gdblib.GdbSetParms{Variable: "step-mode", Value: value})
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		}
+
+		result, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: "step-mode"})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		parms.Enabled = result.Value == "on"
+
+		resultBytes, err := json.Marshal(parms)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/bookmark", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := takeBookmark(mygdb)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		token, err := encodeBookmark(b)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// Opening the link loads the bookmark once the program stops
+		result := struct {
+			Token string
+			Link  string
+		}{token, *basePath + "/?bookmark=" + token}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/bookmark/load", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Token string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		b, err := decodeBookmark(parms.Token)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte("Invalid bookmark: " + err.Error()))
+			return
+		}
+
+		skipped, err := loadBookmark(mygdb, b)
+
+		if err != nil {
+			if strings.HasPrefix(err.Error(), errBookmarkLocation.Error()) {
+				w.WriteHeader(409)
+			} else {
+				w.WriteHeader(400)
+			}
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := struct {
+			Thread          string
+			Frame           int
+			Displays        []string
+			SkippedDisplays []string
+		}{b.Thread, b.Frame, b.Displays, skipped}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/onstop", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			parms := struct {
+				Commands []string
+				Once     bool
+			}{}
+
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			setOnStop(parms.Commands, parms.Once)
+		}
+
+		result := struct {
+			Commands []string
+			Once     bool
+		}{}
+		result.Commands, result.Once = getOnStop()
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/frameverbosity", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			parms := struct {
+				Level string
+			}{}
+
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			if parms.Level != "location" && parms.Level != "arguments" {
+				w.WriteHeader(400)
+				w.Write([]byte("Level must be either location or arguments"))
+				return
+			}
+
+			setFrameVerbosity(parms.Level)
+		}
+
+		result := struct {
+			Level string
+		}{getFrameVerbosity()}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/buildidcheck", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dir := *srcDir
+		if dir == "" {
+			dir = cwd
+		}
+
+		result, err := checkBuildId(mygdb, executablePath, dir)
+
+		if err != nil {
+			if strings.HasPrefix(err.Error(), errUnsupportedPlatform.Error()) {
+				w.WriteHeader(501)
+			} else if err == errNoBuildId {
+				w.WriteHeader(404)
+			} else {
+				w.WriteHeader(400)
+			}
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/breakpending", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Pending string
+		}{}
+
+		if r.Method != "GET" {
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			if parms.Pending != "on" && parms.Pending != "off" && parms.Pending != "auto" {
+				w.WriteHeader(400)
+				w.Write([]byte("Pending must be one of on, off or auto"))
+				return
+			}
+
+			// With on, breakpoints in shared libraries that aren't loaded
+			//  yet are made pending instead of failing to insert.
+			err = mygdb.GdbSet(gdblib.GdbSetParms{Variable: "breakpoint pending", Value: parms.Pending})
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		}
+
+		result, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: "breakpoint pending"})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		parms.Pending = result.Value
+
+		resultBytes, err := json.Marshal(parms)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/skipruntime", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			parms := struct {
+				Enabled bool
+				// Other packages that are skipped along with the runtime
+				Packages []string
+			}{}
+
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			err = setPackageSkips(mygdb, append([]string{"runtime"}, parms.Packages...), parms.Enabled)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		}
+
+		rules, err := listSkips(mygdb)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := struct {
+			Enabled bool
+			Rules   []skipRule
+		}{false, rules}
+
+		for _, rule := range rules {
+			if rule.Enabled && rule.Regexp && rule.Function == packageSkipPattern("runtime") {
+				result.Enabled = true
+			}
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/confirm", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Enabled bool
+		}{}
+
+		if r.Method != "GET" {
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			value := "off"
+			if parms.Enabled {
+				value = "on"
+			}
+
+			err = mygdb.GdbSet(gdblib.GdbSetParms{Variable: "confirm", Value: value})
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		}
+
+		result, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: "confirm"})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		parms.Enabled = result.Value == "on"
+
+		resultBytes, err := json.Marshal(parms)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/backtracelimit", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			// Zero is no limit
+			Limit int
+		}{}
+
+		if r.Method != "GET" {
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			if parms.Limit < 0 {
+				w.WriteHeader(400)
+				w.Write([]byte("Limit can't be negative"))
+				return
+			}
+
+			err = mygdb.GdbSet(gdblib.GdbSetParms{Variable: "backtrace limit", Value: strconv.Itoa(parms.Limit)})
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		}
+
+		result, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: "backtrace limit"})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// Newer versions of gdb show no limit as unlimited
+		parms.Limit, _ = strconv.Atoi(result.Value)
+
+		resultBytes, err := json.Marshal(parms)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/crashreport", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := buildCrashReport(mygdb)
+
+		if err != nil {
+			if err == errNotStoppedOnSignal {
+				w.WriteHeader(409)
+			} else {
+				w.WriteHeader(400)
+			}
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// The text form is downloaded for attaching to a bug report
+		if r.URL.Query().Get("format") == "text" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Header().Set("Content-Disposition", "attachment; filename=crashreport.txt")
+			w.WriteHeader(200)
+			w.Write([]byte(crashReportText(result)))
+			return
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/gdb/interruptonconnect", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Enabled bool
+		}{}
+
+		if r.Method != "GET" {
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			value := int32(0)
+			if parms.Enabled {
+				value = 1
+			}
+			atomic.StoreInt32(&interruptOnConnectEnabled, value)
+		}
+
+		parms.Enabled = atomic.LoadInt32(&interruptOnConnectEnabled) == 1
+
+		resultBytes, err := json.Marshal(parms)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+}
+
+// Matches the checkpoint number in the output of the checkpoint command
+// (e.g. "checkpoint 1: fork returned pid 1234.")
+var checkpointCreatedPattern = regexp.MustCompile(`checkpoint ([0-9]+):`)
+
+// Matches a line of the info checkpoints output
+// (e.g. "* 0 process 1234 (main process) at 0x400c10, file foo.c, line 10")
+var checkpointInfoPattern = regexp.MustCompile(`^\s*(\*?)\s*([0-9]+) (.*)$`)
+
+func addCheckpointHandlers(mygdb *tracedGDB) {
+	handleFunc("/handle/checkpoint/create", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cliOutput, err := cliExec(mygdb, "checkpoint")
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		match := checkpointCreatedPattern.FindStringSubmatch(cliOutput)
+
+		if match == nil {
+			w.WriteHeader(400)
+			w.Write([]byte(cliOutput))
+			return
+		}
+
+		result := struct {
+			Id int
+		}{}
+		result.Id, _ = strconv.Atoi(match[1])
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/checkpoint/list", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cliOutput, err := cliExec(mygdb, "info checkpoints")
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		type checkpoint struct {
+			Id          int
+			Current     bool
+			Description string
+		}
+
+		result := []checkpoint{}
+		for _, line := range strings.Split(cliOutput, "\n") {
+			match := checkpointInfoPattern.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+
+			id, _ := strconv.Atoi(match[2])
+			result = append(result, checkpoint{id, match[1] == "*", match[3]})
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/checkpoint/restart", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Id int
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		_, err = cliExec(mygdb, "restart "+strconv.Itoa(parms.Id))
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.WriteHeader(200)
+	}))
+}
+
+// Matches the architecture in the output of show architecture
+// (e.g. "The target architecture is set automatically (currently i386:x86-64)")
+var architecturePattern = regexp.MustCompile(`(?:currently|assumed to be) ([^) ]+)`)
+
+// Matches the watchpoint number in the output of the watch commands
+// (e.g. "Hardware watchpoint 2: x")
+var watchpointCreatedPattern = regexp.MustCompile(`(Hardware (?:read |access \(read/write\) )?)?[wW]atchpoint ([0-9]+):`)
+
+type watchpointCapacity struct {
+	// Number of hardware watchpoints supported by the target, -1 if unknown
+	Supported int
+	InUse     int
+}
+
+func hardwareWatchpointCapacity(mygdb *tracedGDB) (*watchpointCapacity, error) {
+	capacity := &watchpointCapacity{Supported: -1}
+
+	canUse, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: "can-use-hw-watchpoints"})
+	if err != nil {
+		return nil, err
+	}
+
+	cliOutput, err := cliExec(mygdb, "show architecture")
+	if err != nil {
+		return nil, err
+	}
+
+	// Gdb doesn't report how many debug registers there are. The x86 family
+	//  always has four of them.
+	match := architecturePattern.FindStringSubmatch(cliOutput)
+	if canUse.Value == "0" {
+		capacity.Supported = 0
+	} else if match != nil && strings.HasPrefix(match[1], "i386") {
+		capacity.Supported = 4
+	}
+
+	breakpoints, err := breakList(mygdb)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, breakpoint := range breakpoints {
+		if strings.HasPrefix(breakpoint.Type, "hw watchpoint") ||
+			strings.HasPrefix(breakpoint.Type, "read watchpoint") ||
+			strings.HasPrefix(breakpoint.Type, "acc watchpoint") {
+
+			capacity.InUse++
+		}
+	}
+
+	return capacity, nil
+}
+
+func addWatchpointHandlers(mygdb *tracedGDB) {
+	handleFunc("/handle/watchpoint/capacity", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := hardwareWatchpointCapacity(mygdb)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/watchpoint/insert", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Expression string
+			// One of write (default), read or access
+			Type string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		command := ""
+		switch parms.Type {
+		case "", "write":
+			command = "watch "
+		case "read":
+			command = "rwatch "
+		case "access":
+			command = "awatch "
+		default:
+			w.WriteHeader(400)
+			w.Write([]byte("Unknown watchpoint type: " + parms.Type))
+			return
+		}
+
+		capacity, err := hardwareWatchpointCapacity(mygdb)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		cliOutput, err := cliExec(mygdb, command+parms.Expression)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		match := watchpointCreatedPattern.FindStringSubmatch(cliOutput)
+
+		if match == nil {
+			w.WriteHeader(400)
+			w.Write([]byte(cliOutput))
+			return
+		}
+
+		result := struct {
+			Number   int
+			Hardware bool
+			Warning  string
+		}{}
+		result.Number, _ = strconv.Atoi(match[2])
+		result.Hardware = match[1] != ""
+
+		if !result.Hardware ||
+			(capacity.Supported != -1 && capacity.InUse >= capacity.Supported) {
+
+			result.Warning = "No hardware watchpoints are available, gdb will fall back to much slower software watching"
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+}
+
+func addConsoleHandlers(mygdb *tracedGDB) {
+	handleFunc("/handle/console/tail", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since := int64(0)
+		timeout := 30 * time.Second
+
+		sinceValue := r.URL.Query().Get("since")
+		if sinceValue != "" {
+			var err error
+			since, err = strconv.ParseInt(sinceValue, 10, 64)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		}
+
+		timeoutValue := r.URL.Query().Get("timeout")
+		if timeoutValue != "" {
+			seconds, err := strconv.Atoi(timeoutValue)
+
+			if err != nil || seconds < 0 || seconds > 60 {
+				w.WriteHeader(400)
+				w.Write([]byte("Timeout must be between 0 and 60 seconds"))
+				return
+			}
+			timeout = time.Duration(seconds) * time.Second
+		}
+
+		result := struct {
+			Lines []outputLine
+			// Sequence number to ask for next time
+			Seq int64
+		}{}
+
+		result.Lines, result.Seq = output.tail(since, timeout)
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/console/filter", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Connection int
+			Muted      []string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		for _, category := range parms.Muted {
+			if category != "console" && category != "target" && category != "gdb" && category != "async" {
+				w.WriteHeader(400)
+				w.Write([]byte("Categories must be console, target, gdb or async"))
+				return
+			}
+		}
+
+		if !output.setMuted(parms.Connection, parms.Muted) {
+			w.WriteHeader(404)
+			w.Write([]byte("No websocket connection with id " + strconv.Itoa(parms.Connection)))
+			return
+		}
+
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/console/pause", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Connection int
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if !output.pause(parms.Connection) {
+			w.WriteHeader(404)
+			w.Write([]byte("No websocket connection with id " + strconv.Itoa(parms.Connection)))
+			return
+		}
+
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/console/resume", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Connection int
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := struct {
+			Delivered int
+			Dropped   int
+		}{}
+
+		var ok bool
+		result.Delivered, result.Dropped, ok = output.resume(parms.Connection)
+
+		if !ok {
+			w.WriteHeader(404)
+			w.Write([]byte("No websocket connection with id " + strconv.Itoa(parms.Connection)))
+			return
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+}
+
+// Matches the configured size in the show remote memory-read-packet-size
+// output (e.g. "The memory-read-packet-size is 0. Packets are limited to 4096 bytes.")
+var packetSizePattern = regexp.MustCompile(`memory-read-packet-size is ([0-9]+)`)
+
+func addTargetHandlers(mygdb *tracedGDB) {
+	handleFunc("/handle/target/detach", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The program keeps running after gdb lets go of it
+		_, err := cliExec(mygdb, "detach")
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		atomic.StoreInt32(&detached, 1)
+
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/target/remoteconfig", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			// Seconds to wait for the remote target to reply
+			Timeout int
+			// Largest memory read packet in bytes, 0 lets gdb choose
+			PacketSize int
+		}{}
+
+		if r.Method != "GET" {
+			decoder := json.NewDecoder(r.Body)
+			err := decoder.Decode(&parms)
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			if parms.Timeout < 0 || parms.PacketSize < 0 {
+				w.WriteHeader(400)
+				w.Write([]byte("Timeout and PacketSize can't be negative"))
+				return
+			}
+
+			// A timeout of 0 leaves the current one alone
+			if parms.Timeout > 0 {
+				err = mygdb.GdbSet(gdblib.GdbSetParms{Variable: "remotetimeout", Value: strconv.Itoa(parms.Timeout)})
+			}
+
+			if err == nil {
+				_, err = cliExec(mygdb, "set remote memory-read-packet-size "+strconv.Itoa(parms.PacketSize))
+			}
+
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte(err.Error()))
+				return
+			}
+		}
+
+		timeout, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: "remotetimeout"})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		cliOutput, err := cliExec(mygdb, "show remote memory-read-packet-size")
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		parms.Timeout, _ = strconv.Atoi(timeout.Value)
+		parms.PacketSize = 0
+		if match := packetSizePattern.FindStringSubmatch(cliOutput); match != nil {
+			parms.PacketSize, _ = strconv.Atoi(match[1])
+		}
+
+		resultBytes, err := json.Marshal(parms)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+}
+
+// Matches the tracepoint number in the output of the trace command
+// (e.g. "Tracepoint 2 at 0x400c10: file foo.c, line 10.")
+var tracepointCreatedPattern = regexp.MustCompile(`[tT]racepoint ([0-9]+) at`)
+
+// Writes the error for a tracing command making it clear when the reason is
+// that the target cannot trace at all.
+func writeTraceError(w http.ResponseWriter, err error) {
+	msg := err.Error()
+	if strings.Contains(msg, "does not support") || strings.Contains(msg, "only be run on remote targets") {
+		w.WriteHeader(501)
+		w.Write([]byte("The current target doesn't support tracing: " + msg))
+		return
+	}
+
+	w.WriteHeader(400)
+	w.Write([]byte(msg))
+}
+
+func addTracepointHandlers(mygdb *tracedGDB) {
+	handleFunc("/handle/tracepoint/insert", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Location string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		cliOutput, err := cliExec(mygdb, "trace "+parms.Location)
+
+		if err != nil {
+			writeTraceError(w, err)
+			return
+		}
+
+		match := tracepointCreatedPattern.FindStringSubmatch(cliOutput)
+
+		if match == nil {
+			w.WriteHeader(400)
+			w.Write([]byte(cliOutput))
+			return
+		}
+
+		result := struct {
+			Number int
+		}{}
+		result.Number, _ = strconv.Atoi(match[1])
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/tracepoint/list", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		breakpoints, err := breakList(mygdb)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// Tracepoints are listed along with the breakpoints
+		result := []breakpointInfo{}
+		for _, breakpoint := range breakpoints {
+			if strings.HasSuffix(breakpoint.Type, "tracepoint") {
+				result = append(result, breakpoint)
+			}
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/tracepoint/delete", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Number int
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		breakpoints, err := breakList(mygdb)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// Breakpoints and tracepoints are numbered together so a number that
+		//  isn't a tracepoint's is turned away.
+		found := false
+		for _, breakpoint := range breakpoints {
+			if breakpoint.Number == strconv.Itoa(parms.Number) && strings.HasSuffix(breakpoint.Type, "tracepoint") {
+				found = true
+			}
+		}
+
+		if !found {
+			w.WriteHeader(404)
+			w.Write([]byte("No tracepoint number " + strconv.Itoa(parms.Number)))
+			return
+		}
+
+		_, err = cliExec(mygdb, "delete "+strconv.Itoa(parms.Number))
+
+		if err != nil {
+			writeTraceError(w, err)
+			return
+		}
+
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/tracepoint/collect", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Number      int
+			Expressions []string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if len(parms.Expressions) == 0 {
+			w.WriteHeader(400)
+			w.Write([]byte("No expressions to collect"))
+			return
+		}
+
+		// The actions of a tracepoint are set the same way as the commands
+		//  of a breakpoint.
+		err = mygdb.BreakCommands(gdblib.BreakCommandsParms{Number: strconv.Itoa(parms.Number),
+			Commands: []string{"collect " + strings.Join(parms.Expressions, ", ")}})
+
+		if err != nil {
+			writeTraceError(w, err)
+			return
+		}
+
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/trace/start", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := cliExec(mygdb, "tstart")
+
+		if err != nil {
+			writeTraceError(w, err)
+			return
+		}
+
+		w.WriteHeader(200)
+	}))
+
+	handleFunc("/handle/trace/stop", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := cliExec(mygdb, "tstop")
+
+		if err != nil {
+			writeTraceError(w, err)
+			return
+		}
+
 		w.WriteHeader(200)
 	}))
+}
+
+type memorySnapshot struct {
+	Start uint64
+	Bytes []byte
+}
+
+var (
+	memorySnapshotsMutex sync.Mutex
+	memorySnapshots      = make(map[string]memorySnapshot)
+)
+
+type backtraceFrame struct {
+	Level string `json:"level"`
+	Func  string `json:"func"`
+	File  string `json:"file"`
+	Line  string `json:"line"`
+	Addr  string `json:"addr"`
+}
+
+var (
+	backtraceSnapshotsMutex sync.Mutex
+	backtraceSnapshots      = make(map[string][]backtraceFrame)
+)
+
+// A local variable of a frame as it was when a snapshot was taken
+type frameVariable struct {
+	Name  string
+	Type  string
+	Value string
+}
+
+var (
+	variableSnapshotsMutex sync.Mutex
+	variableSnapshots      = make(map[string][]frameVariable)
+)
+
+func addSnapshotHandlers(mygdb *tracedGDB) {
+	handleFunc("/handle/data/snapshot/create", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Name    string
+			Address string
+			Count   int
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Name == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No snapshot name provided"))
+			return
+		}
+
+		start, memory, err := readMemory(mygdb, parms.Address, parms.Count)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		memorySnapshotsMutex.Lock()
+		memorySnapshots[parms.Name] = memorySnapshot{start, memory}
+		memorySnapshotsMutex.Unlock()
+
+		result := struct {
+			Address string
+			Count   int
+		}{fmt.Sprintf("0x%x", start), len(memory)}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/data/snapshot/diff", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Name string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		memorySnapshotsMutex.Lock()
+		snapshot, ok := memorySnapshots[parms.Name]
+		memorySnapshotsMutex.Unlock()
+
+		if !ok {
+			w.WriteHeader(404)
+			w.Write([]byte("No snapshot named " + parms.Name))
+			return
+		}
+
+		// The same range is read again even if the expression that was used
+		//  for the snapshot would now give a different address.
+		_, memory, err := readMemory(mygdb, fmt.Sprintf("0x%x", snapshot.Start), len(snapshot.Bytes))
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		type byteChange struct {
+			Offset int
+			Old    byte
+			New    byte
+		}
+
+		result := []byteChange{}
+		for offset := range memory {
+			if memory[offset] != snapshot.Bytes[offset] {
+				result = append(result, byteChange{offset, snapshot.Bytes[offset], memory[offset]})
+			}
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/frame/snapshot", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Name string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Name == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No snapshot name provided"))
+			return
+		}
+
+		stackResult, err := mygdb.StackListFrames(gdblib.StackListFramesParms{})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		stack := struct {
+			Stack []backtraceFrame `json:"stack"`
+		}{}
+
+		err = remarshal(stackResult, &stack)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		backtraceSnapshotsMutex.Lock()
+		backtraceSnapshots[parms.Name] = stack.Stack
+		backtraceSnapshotsMutex.Unlock()
+
+		result := struct {
+			Frames int
+		}{len(stack.Stack)}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/frame/diff", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			From string
+			To   string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		backtraceSnapshotsMutex.Lock()
+		from, fromOk := backtraceSnapshots[parms.From]
+		to, toOk := backtraceSnapshots[parms.To]
+		backtraceSnapshotsMutex.Unlock()
+
+		if !fromOk || !toOk {
+			missing := parms.From
+			if fromOk {
+				missing = parms.To
+			}
+			w.WriteHeader(404)
+			w.Write([]byte("No snapshot named " + missing))
+			return
+		}
+
+		result := struct {
+			Identical bool
+			// Number of outermost frames that the backtraces share
+			CommonFrames int
+			From         *backtraceFrame
+			To           *backtraceFrame
+		}{}
+
+		// The backtraces are compared from the outermost frame in since that
+		//  is where two paths through the program start out the same. Frames
+		//  are the same if they're at the same place in the same function.
+		for result.CommonFrames < len(from) && result.CommonFrames < len(to) {
+			fromFrame := from[len(from)-1-result.CommonFrames]
+			toFrame := to[len(to)-1-result.CommonFrames]
+
+			if fromFrame.Func != toFrame.Func || fromFrame.File != toFrame.File || fromFrame.Line != toFrame.Line {
+				break
+			}
+			result.CommonFrames++
+		}
+
+		if result.CommonFrames < len(from) {
+			result.From = &from[len(from)-1-result.CommonFrames]
+		}
+		if result.CommonFrames < len(to) {
+			result.To = &to[len(to)-1-result.CommonFrames]
+		}
+		result.Identical = result.From == nil && result.To == nil
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/frame/variablesnapshot", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Name string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.Name == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No snapshot name provided"))
+			return
+		}
+
+		varsResult, err := mygdb.StackListVariables(gdblib.StackListVariablesParms{AllValues: true})
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		vars := struct {
+			Variables []struct {
+				Name  string `json:"name"`
+				Value string `json:"value"`
+			} `json:"variables"`
+		}{}
+
+		err = remarshal(varsResult, &vars)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// The variables are listed for the selected frame so that is where
+		//  their types are looked up.
+		snapshot := []frameVariable{}
+		for _, variable := range vars.Variables {
+			varType := ""
+			cliOutput, err := cliExec(mygdb, "whatis "+variable.Name)
+			if err == nil {
+				varType = strings.TrimPrefix(strings.TrimSpace(cliOutput), "type = ")
+			}
+
+			snapshot = append(snapshot, frameVariable{variable.Name, varType, variable.Value})
+		}
+
+		variableSnapshotsMutex.Lock()
+		variableSnapshots[parms.Name] = snapshot
+		variableSnapshotsMutex.Unlock()
+
+		resultBytes, err := json.Marshal(snapshot)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/frame/variablesnapshotdiff", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			From string
+			To   string
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		variableSnapshotsMutex.Lock()
+		from, fromOk := variableSnapshots[parms.From]
+		to, toOk := variableSnapshots[parms.To]
+		variableSnapshotsMutex.Unlock()
+
+		if !fromOk || !toOk {
+			missing := parms.From
+			if fromOk {
+				missing = parms.To
+			}
+			w.WriteHeader(404)
+			w.Write([]byte("No snapshot named " + missing))
+			return
+		}
+
+		type changedVariable struct {
+			Name string
+			Type string
+			From string
+			To   string
+		}
+
+		result := struct {
+			Changed []changedVariable
+			Added   []frameVariable
+			Removed []frameVariable
+		}{[]changedVariable{}, []frameVariable{}, []frameVariable{}}
+
+		// Variables are matched up by name, a name that is shadowed in an
+		//  inner block is matched in the order that gdb lists them.
+		fromByName := make(map[string][]frameVariable)
+		for _, variable := range from {
+			fromByName[variable.Name] = append(fromByName[variable.Name], variable)
+		}
+
+		for _, variable := range to {
+			earlier := fromByName[variable.Name]
+			if len(earlier) == 0 {
+				result.Added = append(result.Added, variable)
+				continue
+			}
+
+			fromByName[variable.Name] = earlier[1:]
+			if earlier[0].Value != variable.Value || earlier[0].Type != variable.Type {
+				result.Changed = append(result.Changed, changedVariable{variable.Name, variable.Type,
+					earlier[0].Value, variable.Value})
+			}
+		}
+
+		for _, variable := range from {
+			if len(fromByName[variable.Name]) > 0 {
+				result.Removed = append(result.Removed, fromByName[variable.Name]...)
+				delete(fromByName, variable.Name)
+			}
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+}
+
+func addGoroutineHandlers(mygdb *tracedGDB) {
+	handleFunc("/handle/goroutine/stackinfo", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := currentGoroutineStack(mygdb)
+
+		if err != nil {
+			// Where to find the goroutine depends on the Go runtime
+			if strings.HasPrefix(err.Error(), errUnsupportedGoVersion.Error()) ||
+				strings.HasPrefix(err.Error(), errUnsupportedPlatform.Error()) {
+
+				w.WriteHeader(501)
+			} else {
+				w.WriteHeader(400)
+			}
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+}
+
+// Matches the output of info line for a line with code
+// (e.g. "Line 12 of \"main.go\" starts at address 0x401000 <main.main+16> and ends at 0x401010 <main.main+32>.")
+var lineCodePattern = regexp.MustCompile(`Line ([0-9]+) of "[^"]*"\s+starts at address`)
+
+// Matches the output of info line for a line without code
+// (e.g. "Line 11 of \"main.go\" is at address 0x401000 <main.main+16> but contains no code.")
+var lineNoCodePattern = regexp.MustCompile(`is at address (0x[0-9a-fA-F]+).*but contains no code`)
+
+func addSymbolHandlers(mygdb *tracedGDB) {
+	handleFunc("/handle/symbol/islinecode", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			File string
+			Line int
+		}{}
+
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&parms)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if parms.File == "" || parms.Line < 1 {
+			w.WriteHeader(400)
+			w.Write([]byte("A file and line must be provided"))
+			return
+		}
+
+		cliOutput, err := cliExec(mygdb, "info line "+parms.File+":"+strconv.Itoa(parms.Line))
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := struct {
+			HasCode bool
+			// Nearest line with code, zero if there isn't one
+			NearestLine int
+		}{}
+
+		if lineCodePattern.MatchString(cliOutput) {
+			result.HasCode = true
+			result.NearestLine = parms.Line
+		} else if match := lineNoCodePattern.FindStringSubmatch(cliOutput); match != nil {
+			// Gdb gives the address of the next line that has code
+			addrOutput, err := cliExec(mygdb, "info line *"+match[1])
+
+			if err == nil {
+				lineMatch := lineCodePattern.FindStringSubmatch(addrOutput)
+				if lineMatch != nil {
+					result.NearestLine, _ = strconv.Atoi(lineMatch[1])
+				}
+			}
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+}
 
-	http.HandleFunc("/handle/exec/run", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		parms := gdblib.ExecRunParms{}
+// Matches the method in the first line of the info record output
+// (e.g. "Active record target: record-full")
+var recordTargetPattern = regexp.MustCompile(`Active record target: (\S+)`)
+
+// Matches the number of instructions in the record log
+// (e.g. "Log contains 152 instructions.")
+var recordLogPattern = regexp.MustCompile(`Log contains ([0-9]+) instructions`)
+
+// Matches the size of the record log
+// (e.g. "Max logged instructions is 200000.")
+var recordMaxPattern = regexp.MustCompile(`Max logged instructions is ([0-9]+)`)
+
+// Matches the number of instructions recorded with branch tracing
+// (e.g. "Recorded 1234 instructions in 56 functions (0 gaps) for thread 1 ...")
+var recordBtracePattern = regexp.MustCompile(`Recorded ([0-9]+) instructions`)
+
+// Matches the oldest instruction number of a full recording
+// (e.g. "Lowest recorded instruction number is 1.")
+var recordLowestPattern = regexp.MustCompile(`Lowest recorded instruction number is ([0-9]+)`)
+
+// Matches the newest instruction number of a full recording
+// (e.g. "Highest recorded instruction number is 152.")
+var recordHighestPattern = regexp.MustCompile(`Highest recorded instruction number is ([0-9]+)`)
+
+// Resolves the path of a record file for saving or restoring making sure
+// that gdb can be given it.
+func recordFilePath(file string) (string, error) {
+	path, err := sourceFilePath(file)
+	if err != nil {
+		return "", err
+	}
+
+	// Gdb has no way to quote the file name of the record commands
+	if strings.ContainsAny(path, " \t") {
+		return "", errors.New("The file path can't contain spaces")
+	}
+
+	return path, nil
+}
+
+func addRecordHandlers(mygdb *tracedGDB) {
+	handleFunc("/handle/record/status", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cliOutput, err := cliExec(mygdb, "info record")
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := struct {
+			Active       bool
+			Method       string
+			Instructions int
+			// The size of the log and how much of it is used, only the
+			//  full recording method has a limit.
+			MaxInstructions int
+			Usage           float64
+			Replaying       bool
+		}{}
+
+		if match := recordTargetPattern.FindStringSubmatch(cliOutput); match != nil {
+			result.Active = true
+			result.Method = match[1]
+		}
+
+		if match := recordLogPattern.FindStringSubmatch(cliOutput); match != nil {
+			result.Instructions, _ = strconv.Atoi(match[1])
+		} else if match := recordBtracePattern.FindStringSubmatch(cliOutput); match != nil {
+			result.Instructions, _ = strconv.Atoi(match[1])
+		}
+
+		if match := recordMaxPattern.FindStringSubmatch(cliOutput); match != nil {
+			result.MaxInstructions, _ = strconv.Atoi(match[1])
+			if result.MaxInstructions > 0 {
+				result.Usage = float64(result.Instructions) / float64(result.MaxInstructions)
+			}
+		}
+
+		result.Replaying = strings.Contains(cliOutput, "Replay mode") ||
+			strings.Contains(cliOutput, "Replay in progress")
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/record/save", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkAllowWrite(w) || !checkInferior(w) {
+			return
+		}
+
+		parms := struct {
+			File string
+		}{}
 
 		decoder := json.NewDecoder(r.Body)
 		err := decoder.Decode(&parms)
 
-		if err == nil {
-			err = mygdb.ExecRun(parms)
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
 		}
 
+		path, err := recordFilePath(parms.File)
+
 		if err != nil {
 			w.WriteHeader(400)
 			w.Write([]byte(err.Error()))
 			return
 		}
-		w.WriteHeader(200)
+
+		// The path can be anywhere that the source is read from so nothing
+		//  that is already there (e.g. a source file) is overwritten.
+		if _, err := os.Lstat(path); err == nil {
+			w.WriteHeader(409)
+			w.Write([]byte(path + " already exists"))
+			return
+		}
+
+		_, err = cliExec(mygdb, "record save "+path)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result := struct {
+			File string
+		}{path}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
 	}))
 
-	http.HandleFunc("/handle/exec/args", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		parms := gdblib.ExecArgsParms{}
+	handleFunc("/handle/record/load", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			File string
+		}{}
 
 		decoder := json.NewDecoder(r.Body)
 		err := decoder.Decode(&parms)
 
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		path, err := recordFilePath(parms.File)
+
 		if err == nil {
-			err = mygdb.ExecArgs(parms)
+			_, err = os.Stat(path)
+		}
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
 		}
 
+		// No running program is needed since the file holds a core file of
+		//  where the recording started. The recording is replayed from its
+		//  end.
+		_, err = cliExec(mygdb, "record restore "+path)
+
 		if err != nil {
 			w.WriteHeader(400)
 			w.Write([]byte(err.Error()))
 			return
 		}
+
 		w.WriteHeader(200)
 	}))
 
-	http.HandleFunc("/handle/exec/interrupt", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		parms := gdblib.ExecInterruptParms{}
+	handleFunc("/handle/exec/gotoinstruction", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkInferior(w) {
+			return
+		}
+
+		parms := struct {
+			Count int
+		}{}
 
 		decoder := json.NewDecoder(r.Body)
 		err := decoder.Decode(&parms)
 
-		mygdb.ExecInterrupt(parms)
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		cliOutput, err := cliExec(mygdb, "info record")
 
 		if err != nil {
 			w.WriteHeader(400)
 			w.Write([]byte(err.Error()))
 			return
 		}
-		w.WriteHeader(200)
-	}))
-}
 
-func addBreakpointHandlers(mygdb *gdblib.GDB) {
-	http.HandleFunc("/handle/breakpoint/list", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		result, err := mygdb.BreakList()
+		if !recordTargetPattern.MatchString(cliOutput) {
+			w.WriteHeader(409)
+			w.Write([]byte("The program isn't being recorded"))
+			return
+		}
+
+		// Branch tracing numbers the instructions from 1 but the full
+		//  recording drops the oldest ones once its log is full.
+		lowest, highest := 1, 0
+		if match := recordLowestPattern.FindStringSubmatch(cliOutput); match != nil {
+			lowest, _ = strconv.Atoi(match[1])
+		}
+		if match := recordHighestPattern.FindStringSubmatch(cliOutput); match != nil {
+			highest, _ = strconv.Atoi(match[1])
+		} else if match := recordBtracePattern.FindStringSubmatch(cliOutput); match != nil {
+			highest, _ = strconv.Atoi(match[1])
+		}
+
+		if parms.Count < lowest || parms.Count > highest {
+			w.WriteHeader(400)
+			w.Write([]byte(fmt.Sprintf("Count must be between %v and %v", lowest, highest)))
+			return
+		}
+
+		// Gdb replays forwards or backwards to the instruction itself which
+		//  is the same as stepping there one instruction at a time.
+		_, err = cliExec(mygdb, "record goto "+strconv.Itoa(parms.Count))
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		frameResult, err := mygdb.StackInfoFrame()
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		frame := struct {
+			Frame backtraceFrame `json:"frame"`
+		}{}
+
+		err = remarshal(frameResult, &frame)
 
 		if err != nil {
 			w.WriteHeader(500)
@@ -757,6 +7893,13 @@ func addBreakpointHandlers(mygdb *gdblib.GDB) {
 			return
 		}
 
+		result := struct {
+			Instruction int
+			Lowest      int
+			Highest     int
+			Frame       backtraceFrame
+		}{parms.Count, lowest, highest, frame.Frame}
+
 		resultBytes, err := json.Marshal(result)
 
 		if err != nil {
@@ -767,12 +7910,57 @@ func addBreakpointHandlers(mygdb *gdblib.GDB) {
 			w.Write(resultBytes)
 		}
 	}))
+}
 
-	http.HandleFunc("/handle/breakpoint/insert", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		parms := gdblib.BreakInsertParms{}
+// Matches the number of the inferior in the output of add-inferior
+// (e.g. "Added inferior 2")
+var inferiorAddedPattern = regexp.MustCompile(`Added inferior ([0-9]+)`)
 
-		decoder := json.NewDecoder(r.Body)
-		err := decoder.Decode(&parms)
+// Matches a line of the info inferiors output, newer versions of gdb have a
+// connection column before the executable
+// (e.g. "* 1    process 12345     1 (native)    /home/user/bin/foo")
+var inferiorInfoPattern = regexp.MustCompile(`^\s*(\*?)\s*([0-9]+)\s+(.*?)\s*$`)
+
+type inferiorInfo struct {
+	Id          int
+	Current     bool
+	Description string
+	Executable  string
+}
+
+func listInferiors(mygdb *tracedGDB) ([]inferiorInfo, error) {
+	cliOutput, err := cliExec(mygdb, "info inferiors")
+	if err != nil {
+		return nil, err
+	}
+
+	inferiors := []inferiorInfo{}
+	for _, line := range strings.Split(cliOutput, "\n") {
+		match := inferiorInfoPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		inferior := inferiorInfo{Current: match[1] == "*", Description: match[3]}
+		inferior.Id, _ = strconv.Atoi(match[2])
+
+		// The executable is the last column when there is one
+		fields := strings.Fields(match[3])
+		if len(fields) > 0 && filepath.IsAbs(fields[len(fields)-1]) {
+			inferior.Executable = fields[len(fields)-1]
+		}
+
+		inferiors = append(inferiors, inferior)
+	}
+
+	return inferiors, nil
+}
+
+// The execution, thread and frame commands all apply to the inferior that gdb
+// has selected so once another one is selected they act on it.
+func addInferiorHandlers(mygdb *tracedGDB) {
+	handleFunc("/handle/inferior/list", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := listInferiors(mygdb)
 
 		if err != nil {
 			w.WriteHeader(400)
@@ -780,7 +7968,25 @@ func addBreakpointHandlers(mygdb *gdblib.GDB) {
 			return
 		}
 
-		result, err := mygdb.BreakInsert(parms)
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/inferior/add", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkNotCore(w) {
+			return
+		}
+
+		// The new inferior runs the same program so that two runs of it can
+		//  be compared.
+		cliOutput, err := cliExec(mygdb, "add-inferior -exec "+executablePath)
 
 		if err != nil {
 			w.WriteHeader(400)
@@ -788,6 +7994,19 @@ func addBreakpointHandlers(mygdb *gdblib.GDB) {
 			return
 		}
 
+		match := inferiorAddedPattern.FindStringSubmatch(cliOutput)
+
+		if match == nil {
+			w.WriteHeader(400)
+			w.Write([]byte(strings.TrimSpace(cliOutput)))
+			return
+		}
+
+		result := struct {
+			Id int
+		}{}
+		result.Id, _ = strconv.Atoi(match[1])
+
 		resultBytes, err := json.Marshal(result)
 
 		if err != nil {
@@ -799,8 +8018,10 @@ func addBreakpointHandlers(mygdb *gdblib.GDB) {
 		}
 	}))
 
-	http.HandleFunc("/handle/breakpoint/enable", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		parms := gdblib.BreakEnableParms{}
+	handleFunc("/handle/inferior/select", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Id int
+		}{}
 
 		decoder := json.NewDecoder(r.Body)
 		err := decoder.Decode(&parms)
@@ -811,7 +8032,21 @@ func addBreakpointHandlers(mygdb *gdblib.GDB) {
 			return
 		}
 
-		err = mygdb.BreakEnable(parms)
+		if parms.Id < 1 {
+			w.WriteHeader(400)
+			w.Write([]byte("No inferior provided"))
+			return
+		}
+
+		_, err = cliExec(mygdb, "inferior "+strconv.Itoa(parms.Id))
+
+		if err != nil {
+			w.WriteHeader(404)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		result, err := listInferiors(mygdb)
 
 		if err != nil {
 			w.WriteHeader(400)
@@ -819,11 +8054,26 @@ func addBreakpointHandlers(mygdb *gdblib.GDB) {
 			return
 		}
 
-		w.WriteHeader(200)
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
 	}))
+}
 
-	http.HandleFunc("/handle/breakpoint/disable", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		parms := gdblib.BreakDisableParms{}
+func addSkipHandlers(mygdb *tracedGDB) {
+	handleFunc("/handle/skip/add", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			// Glob of the files to skip (e.g. "*_gen.go")
+			File string
+			// Regular expression of the functions to skip (e.g. "^log\.")
+			Function string
+		}{}
 
 		decoder := json.NewDecoder(r.Body)
 		err := decoder.Decode(&parms)
@@ -834,7 +8084,22 @@ func addBreakpointHandlers(mygdb *gdblib.GDB) {
 			return
 		}
 
-		err = mygdb.BreakDisable(parms)
+		if parms.File == "" && parms.Function == "" {
+			w.WriteHeader(400)
+			w.Write([]byte("No file or function provided"))
+			return
+		}
+
+		// Giving both only skips the functions that are in the files
+		command := "skip"
+		if parms.File != "" {
+			command += " -gfile " + parms.File
+		}
+		if parms.Function != "" {
+			command += " -rfunction " + parms.Function
+		}
+
+		_, err = cliExec(mygdb, command)
 
 		if err != nil {
 			w.WriteHeader(400)
@@ -842,16 +8107,7 @@ func addBreakpointHandlers(mygdb *gdblib.GDB) {
 			return
 		}
 
-		w.WriteHeader(200)
-	}))
-}
-
-func addVariableHandlers(mygdb *gdblib.GDB) {
-	http.HandleFunc("/handle/variable/create", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		parms := gdblib.VarCreateParms{}
-
-		decoder := json.NewDecoder(r.Body)
-		err := decoder.Decode(&parms)
+		rules, err := listSkips(mygdb)
 
 		if err != nil {
 			w.WriteHeader(400)
@@ -859,11 +8115,31 @@ func addVariableHandlers(mygdb *gdblib.GDB) {
 			return
 		}
 
-		result, err := mygdb.VarCreate(parms)
+		// The new rule has the highest number
+		result := skipRule{}
+		for _, rule := range rules {
+			if rule.Number > result.Number {
+				result = rule
+			}
+		}
+
+		resultBytes, err := json.Marshal(result)
 
 		if err != nil {
 			w.WriteHeader(500)
 			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resultBytes)
+		}
+	}))
+
+	handleFunc("/handle/skip/list", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := listSkips(mygdb)
+
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
 			return
 		}
 
@@ -878,8 +8154,11 @@ func addVariableHandlers(mygdb *gdblib.GDB) {
 		}
 	}))
 
-	http.HandleFunc("/handle/variable/delete", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		parms := gdblib.VarDeleteParms{}
+	handleFunc("/handle/skip/enable", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Number  int
+			Enabled bool
+		}{}
 
 		decoder := json.NewDecoder(r.Body)
 		err := decoder.Decode(&parms)
@@ -890,10 +8169,21 @@ func addVariableHandlers(mygdb *gdblib.GDB) {
 			return
 		}
 
-		err = mygdb.VarDelete(parms)
+		if parms.Number < 1 {
+			w.WriteHeader(400)
+			w.Write([]byte("No skip rule number provided"))
+			return
+		}
+
+		command := "skip disable "
+		if parms.Enabled {
+			command = "skip enable "
+		}
+
+		_, err = cliExec(mygdb, command+strconv.Itoa(parms.Number))
 
 		if err != nil {
-			w.WriteHeader(500)
+			w.WriteHeader(400)
 			w.Write([]byte(err.Error()))
 			return
 		}
@@ -901,8 +8191,10 @@ func addVariableHandlers(mygdb *gdblib.GDB) {
 		w.WriteHeader(200)
 	}))
 
-	http.HandleFunc("/handle/variable/listchildren", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		parms := gdblib.VarListChildrenParms{}
+	handleFunc("/handle/skip/delete", wrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parms := struct {
+			Number int
+		}{}
 
 		decoder := json.NewDecoder(r.Body)
 		err := decoder.Decode(&parms)
@@ -913,22 +8205,20 @@ func addVariableHandlers(mygdb *gdblib.GDB) {
 			return
 		}
 
-		result, err := mygdb.VarListChildren(parms)
-
-		if err != nil {
-			w.WriteHeader(500)
-			w.Write([]byte(err.Error()))
+		if parms.Number < 1 {
+			w.WriteHeader(400)
+			w.Write([]byte("No skip rule number provided"))
 			return
 		}
 
-		resultBytes, err := json.Marshal(result)
+		_, err = cliExec(mygdb, "skip delete "+strconv.Itoa(parms.Number))
 
 		if err != nil {
-			w.WriteHeader(500)
+			w.WriteHeader(400)
 			w.Write([]byte(err.Error()))
-		} else {
-			w.WriteHeader(200)
-			w.Write(resultBytes)
+			return
 		}
+
+		w.WriteHeader(200)
 	}))
 }
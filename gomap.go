@@ -0,0 +1,196 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirnewton01/gdblib"
+	"regexp"
+	"strconv"
+)
+
+const (
+	// Number of key/value slots in each hash map bucket
+	goMapBucketSize = 8
+	// Maximum number of entries returned for a single map
+	maxGoMapEntries = 1000
+)
+
+// The layout of the runtime's hash map that is walked below is the one used
+// by Go 1.2 and 1.3. Each bucket starts with the top byte of the hash for
+// each of its slots (zero when the slot is empty) and the linker describes
+// the buckets to gdb as bucket<K,V> with tophash, overflow, keys and values
+// fields. Later versions moved the overflow pointer and changed the meaning
+// of the top hash bytes so they aren't supported.
+var goMapVersions = map[int]bool{2: true, 3: true}
+
+// Matches the version string compiled into the program (e.g. "go1.2.1")
+var goVersionPattern = regexp.MustCompile(`"go1\.([0-9]+)`)
+
+// Flags of the hash map header that mark keys and values stored indirectly
+const (
+	goMapIndirectKey   = 1
+	goMapIndirectValue = 2
+)
+
+var errUnsupportedGoVersion = errors.New("Unsupported Go version")
+
+type goMapEntry struct {
+	Key   string
+	Value string
+}
+
+// Returns the minor version of the Go runtime that the program was built
+// with.
+func goRuntimeVersion(mygdb *tracedGDB) (int, error) {
+	result, err := mygdb.DataEvaluateExpression(gdblib.DataEvaluateExpressionParms{Expression: "runtime.buildVersion"})
+	if err != nil {
+		return 0, err
+	}
+
+	match := goVersionPattern.FindStringSubmatch(result.Value)
+	if match == nil {
+		return 0, fmt.Errorf("%v: %v", errUnsupportedGoVersion, result.Value)
+	}
+
+	return strconv.Atoi(match[1])
+}
+
+func evaluate(mygdb *tracedGDB, expression string) (string, error) {
+	result, err := mygdb.DataEvaluateExpression(gdblib.DataEvaluateExpressionParms{Expression: expression})
+	if err != nil {
+		return "", err
+	}
+
+	return result.Value, nil
+}
+
+func evaluateAddress(mygdb *tracedGDB, expression string) (uint64, error) {
+	value, err := evaluate(mygdb, expression)
+	if err != nil {
+		return 0, err
+	}
+
+	address, ok := parseAddress(addressPattern.FindString(value))
+	if !ok {
+		return 0, fmt.Errorf("Not a pointer: %v", value)
+	}
+
+	return address, nil
+}
+
+func evaluateInt(mygdb *tracedGDB, expression string) (int, error) {
+	value, err := evaluate(mygdb, expression)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(value)
+}
+
+// Walks the buckets of the Go map that the expression evaluates to
+// returning its entries and the number of entries that the map holds.
+func goMapEntries(mygdb *tracedGDB, expression string) ([]goMapEntry, int, error) {
+	version, err := goRuntimeVersion(mygdb)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !goMapVersions[version] {
+		return nil, 0, fmt.Errorf("%v: go1.%v", errUnsupportedGoVersion, version)
+	}
+
+	m := "(" + expression + ")"
+
+	count, err := evaluateInt(mygdb, m+".count")
+	if err != nil {
+		return nil, 0, err
+	}
+	b, err := evaluateInt(mygdb, m+".B")
+	if err != nil {
+		return nil, 0, err
+	}
+	flags, err := evaluateInt(mygdb, m+".flags")
+	if err != nil {
+		return nil, 0, err
+	}
+	oldBuckets, err := evaluateAddress(mygdb, m+".oldbuckets")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries := []goMapEntry{}
+	if count == 0 {
+		return entries, count, nil
+	}
+
+	for bucket := 0; bucket < 1<<uint(b) && len(entries) < maxGoMapEntries; bucket++ {
+		bucketExpr := "(" + m + ".buckets + " + strconv.Itoa(bucket) + ")"
+
+		// While the map is growing the entries may still be in the old
+		//  bucket that this one is split from.
+		if oldBuckets != 0 && b > 0 {
+			oldBucket := bucket & (1<<uint(b-1) - 1)
+			oldBucketExpr := "(" + m + ".oldbuckets + " + strconv.Itoa(oldBucket) + ")"
+
+			overflow, err := evaluateAddress(mygdb, oldBucketExpr+".overflow")
+			if err != nil {
+				return nil, 0, err
+			}
+
+			if overflow&1 == 0 {
+				if bucket >= 1<<uint(b-1) {
+					continue
+				}
+				bucketExpr = oldBucketExpr
+			}
+		}
+
+		for len(entries) < maxGoMapEntries {
+			address, err := evaluateAddress(mygdb, bucketExpr)
+			if err != nil {
+				return nil, 0, err
+			}
+			if address == 0 {
+				break
+			}
+
+			_, tophash, err := readMemory(mygdb, "0x"+strconv.FormatUint(address, 16), goMapBucketSize)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			for slot, hash := range tophash {
+				if hash == 0 {
+					continue
+				}
+
+				keyExpr := bucketExpr + ".keys[" + strconv.Itoa(slot) + "]"
+				if flags&goMapIndirectKey != 0 {
+					keyExpr = "*" + keyExpr
+				}
+				valueExpr := bucketExpr + ".values[" + strconv.Itoa(slot) + "]"
+				if flags&goMapIndirectValue != 0 {
+					valueExpr = "*" + valueExpr
+				}
+
+				key, err := evaluate(mygdb, keyExpr)
+				if err != nil {
+					return nil, 0, err
+				}
+				value, err := evaluate(mygdb, valueExpr)
+				if err != nil {
+					return nil, 0, err
+				}
+
+				entries = append(entries, goMapEntry{key, value})
+			}
+
+			bucketExpr = "(" + bucketExpr + ".overflow)"
+		}
+	}
+
+	return entries, count, nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Since Go 1.4 the g structure of each goroutine starts with the bounds of
+// its stack followed by the two stack guards, each of them a uintptr. Go 1.5
+// and later keep the current g in the thread local storage just below the
+// thread pointer on linux/amd64, which is the only platform supported here.
+const (
+	minGoroutineStackVersion = 5
+	goroutineStackFields     = 4
+	goroutineTlsOffset       = 8
+)
+
+var errUnsupportedPlatform = errors.New("Unsupported platform")
+
+type goroutineStack struct {
+	G           string
+	Lo          string
+	Hi          string
+	StackGuard0 string
+	StackGuard1 string
+	SP          string
+	// Size of the whole stack and of the part in use below the top
+	Size uint64
+	Used uint64
+}
+
+// Reads the stack bounds of the goroutine running on the selected thread
+func currentGoroutineStack(mygdb *tracedGDB) (*goroutineStack, error) {
+	version, err := goRuntimeVersion(mygdb)
+	if err != nil {
+		return nil, err
+	}
+	if version < minGoroutineStackVersion {
+		return nil, fmt.Errorf("%v: go1.%v", errUnsupportedGoVersion, version)
+	}
+
+	cliOutput, err := cliExec(mygdb, "show architecture")
+	if err != nil {
+		return nil, err
+	}
+	match := architecturePattern.FindStringSubmatch(cliOutput)
+	if match == nil || !strings.Contains(match[1], "x86-64") {
+		return nil, fmt.Errorf("%v: only amd64 is supported", errUnsupportedPlatform)
+	}
+
+	g, err := evaluateAddress(mygdb, fmt.Sprintf("*(void **)($fs_base - %v)", goroutineTlsOffset))
+	if err != nil {
+		return nil, err
+	}
+	if g == 0 {
+		return nil, fmt.Errorf("The selected thread isn't running a goroutine")
+	}
+
+	_, memory, err := readMemory(mygdb, fmt.Sprintf("0x%x", g), goroutineStackFields*8)
+	if err != nil {
+		return nil, err
+	}
+	if len(memory) < goroutineStackFields*8 {
+		return nil, fmt.Errorf("Could only read %v bytes of the goroutine", len(memory))
+	}
+
+	sp, err := evaluateAddress(mygdb, "$sp")
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]uint64, goroutineStackFields)
+	for i := range fields {
+		fields[i] = binary.LittleEndian.Uint64(memory[i*8:])
+	}
+	lo, hi := fields[0], fields[1]
+
+	stack := &goroutineStack{G: fmt.Sprintf("0x%x", g), Lo: fmt.Sprintf("0x%x", lo),
+		Hi: fmt.Sprintf("0x%x", hi), StackGuard0: fmt.Sprintf("0x%x", fields[2]),
+		StackGuard1: fmt.Sprintf("0x%x", fields[3]), SP: fmt.Sprintf("0x%x", sp)}
+
+	if hi > lo {
+		stack.Size = hi - lo
+	}
+	// The stack pointer is off of the goroutine's stack when the thread is
+	//  running on the system stack (e.g. in a cgo call).
+	if sp >= lo && sp <= hi {
+		stack.Used = hi - sp
+	}
+
+	return stack, nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Number of the most recent durations kept for each handler or MI
+	// command to work out the percentiles from
+	maxLatencySamples = 1000
+)
+
+type latencySamples struct {
+	count   int64
+	total   time.Duration
+	samples []time.Duration
+	next    int
+}
+
+type latencySummary struct {
+	Name   string
+	Count  int64
+	MeanMs float64
+	P50Ms  float64
+	P99Ms  float64
+}
+
+// How long each of a kind of operation takes, keyed by its name
+type latencyMetrics struct {
+	mutex  sync.Mutex
+	series map[string]*latencySamples
+}
+
+// How long gdb takes to answer each kind of MI command
+var commandLatency = latencyMetrics{series: make(map[string]*latencySamples)}
+
+// How long each handler takes to respond, which includes waiting for the
+// program (e.g. long polls) and for other requests
+var handlerLatency = latencyMetrics{series: make(map[string]*latencySamples)}
+
+func (m *latencyMetrics) record(name string, duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	samples := m.series[name]
+	if samples == nil {
+		samples = &latencySamples{}
+		m.series[name] = samples
+	}
+
+	samples.count++
+	samples.total += duration
+
+	// The samples wrap around once there are enough of them
+	if len(samples.samples) < maxLatencySamples {
+		samples.samples = append(samples.samples, duration)
+	} else {
+		samples.samples[samples.next] = duration
+		samples.next = (samples.next + 1) % maxLatencySamples
+	}
+}
+
+func (m *latencyMetrics) summary() []latencySummary {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	result := []latencySummary{}
+	for name, samples := range m.series {
+		sorted := append([]time.Duration{}, samples.samples...)
+		sort.Sort(durations(sorted))
+
+		percentile := func(p int) float64 {
+			return milliseconds(sorted[(len(sorted)-1)*p/100])
+		}
+
+		result = append(result, latencySummary{name, samples.count,
+			milliseconds(samples.total) / float64(samples.count), percentile(50), percentile(99)})
+	}
+
+	sort.Sort(latencySummaries(result))
+	return result
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+type latencySummaries []latencySummary
+
+func (s latencySummaries) Len() int           { return len(s) }
+func (s latencySummaries) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s latencySummaries) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Lays out one summary metric in the Prometheus text exposition format
+func prometheusSummary(metric string, help string, labelName string, summaries []latencySummary) []string {
+	lines := []string{
+		"# HELP " + metric + " " + help,
+		"# TYPE " + metric + " summary",
+	}
+
+	for _, summary := range summaries {
+		label := fmt.Sprintf("%v=%q", labelName, summary.Name)
+		lines = append(lines,
+			fmt.Sprintf("%v{%v,quantile=\"0.5\"} %v", metric, label, summary.P50Ms),
+			fmt.Sprintf("%v{%v,quantile=\"0.99\"} %v", metric, label, summary.P99Ms),
+			fmt.Sprintf("%v_sum{%v} %v", metric, label, summary.MeanMs*float64(summary.Count)),
+			fmt.Sprintf("%v_count{%v} %v", metric, label, summary.Count))
+	}
+
+	return lines
+}
+
+// Writes the summaries in the Prometheus text exposition format
+func writePrometheusMetrics(w http.ResponseWriter, commands []latencySummary, handlers []latencySummary) {
+	lines := prometheusSummary("godbg_mi_command_latency_milliseconds", "Time taken by gdb to answer MI commands",
+		"command", commands)
+	lines = append(lines, prometheusSummary("godbg_handler_latency_milliseconds", "Time taken to handle requests",
+		"handler", handlers)...)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(200)
+	w.Write([]byte(strings.Join(lines, "\n") + "\n"))
+}
+
+func wrapMetrics(path string, delegate handlerFunc) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		delegate(w, r)
+		handlerLatency.record(path, time.Since(start))
+	}
+}
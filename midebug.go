@@ -0,0 +1,116 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// The MI exchange for a request with ?debug=1: the commands that were sent
+// for it with their result records, and gdb's log stream (where it reports
+// rejected commands), console output and async records. The streams aren't
+// tied to a command so they hold anything that gdb sent while the request
+// was handled.
+type miExchange struct {
+	Commands []miCommand
+	Log      []interface{}
+	Console  []interface{}
+	Target   []interface{}
+	Records  []interface{}
+}
+
+type miDebugResponse struct {
+	Status   int
+	Response interface{}
+	Mi       miExchange `json:"mi"`
+}
+
+// Holds back the status and body of a response so that they can be wrapped
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = 200
+	}
+	return w.body.Write(b)
+}
+
+// With ?debug=1 the response is wrapped in an envelope with the MI exchange
+// for the request. Only the commands that the handler sends itself are
+// listed, not those sent by other requests or from goroutines that the
+// handler starts.
+func wrapMiDebug(delegate handlerFunc) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("debug") != "1" {
+			delegate(w, r)
+			return
+		}
+
+		tracer := watchMi()
+		watcher := output.watchMessages()
+
+		recorder := &bufferedResponseWriter{ResponseWriter: w}
+		delegate(recorder, r)
+
+		// Anything that gdb sent before the result has to make it through
+		//  the broadcaster first.
+		output.flush()
+		output.unwatchMessages(watcher)
+		unwatchMi(tracer)
+
+		result := miDebugResponse{Status: recorder.status}
+		if result.Status == 0 {
+			result.Status = 200
+		}
+
+		// Bodies that aren't JSON (e.g. error messages) are kept as text
+		var body interface{}
+		if json.Unmarshal(recorder.body.Bytes(), &body) == nil {
+			result.Response = body
+		} else {
+			result.Response = recorder.body.String()
+		}
+
+		result.Mi = miExchange{[]miCommand{}, []interface{}{}, []interface{}{}, []interface{}{}, []interface{}{}}
+		for len(tracer) > 0 {
+			result.Mi.Commands = append(result.Mi.Commands, <-tracer)
+		}
+		for len(watcher) > 0 {
+			msg := <-watcher
+			switch msg.Type {
+			case "gdb":
+				result.Mi.Log = append(result.Mi.Log, msg.Data)
+			case "console":
+				result.Mi.Console = append(result.Mi.Console, msg.Data)
+			case "target", "target-raw":
+				result.Mi.Target = append(result.Mi.Target, msg.Data)
+			case "async":
+				result.Mi.Records = append(result.Mi.Records, msg.Data)
+			}
+		}
+
+		resultBytes, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(result.Status)
+			w.Write(resultBytes)
+		}
+	}
+}
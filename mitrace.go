@@ -0,0 +1,308 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"github.com/sirnewton01/gdblib"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// An MI command that was sent to gdb with the parameters that gdblib was
+// given for it and the result record that it handed back
+type miCommand struct {
+	Time       time.Time
+	Command    string
+	DurationMs float64
+	Parms      interface{} `json:",omitempty"`
+	Result     interface{} `json:",omitempty"`
+	Error      string      `json:",omitempty"`
+}
+
+// The requests with ?debug=1 that are being handled, each one hears about
+// the MI commands sent from the goroutine that handles it
+var miTracers = struct {
+	sync.Mutex
+	tracers map[chan miCommand]int64
+}{tracers: make(map[chan miCommand]int64)}
+
+// Returns the id of the calling goroutine. Each request is handled in a
+// goroutine of its own so this tells which request an MI command was sent
+// for.
+func goroutineId() int64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+
+	// The stack starts with "goroutine 123 [running]:"
+	fields := bytes.Fields(buf)
+	if len(fields) < 2 {
+		return 0
+	}
+	id, _ := strconv.ParseInt(string(fields[1]), 10, 64)
+	return id
+}
+
+// Starts collecting the MI commands that the calling goroutine sends
+func watchMi() chan miCommand {
+	miTracers.Lock()
+	defer miTracers.Unlock()
+
+	tracer := make(chan miCommand, maxPendingMessages)
+	miTracers.tracers[tracer] = goroutineId()
+	return tracer
+}
+
+func unwatchMi(tracer chan miCommand) {
+	miTracers.Lock()
+	defer miTracers.Unlock()
+
+	delete(miTracers.tracers, tracer)
+}
+
+// Reports an MI command that was started at the given time and has just
+// completed
+func traceMi(command string, start time.Time, parms interface{}, result interface{}, err error) {
+	duration := time.Since(start)
+	commandLatency.record(command, duration)
+
+	cmd := miCommand{Time: start, Command: command, DurationMs: milliseconds(duration), Parms: parms, Result: result}
+	if err != nil {
+		cmd.Error = err.Error()
+	}
+
+	sessionTranscript.recordCommand(cmd)
+
+	miTracers.Lock()
+	defer miTracers.Unlock()
+
+	if len(miTracers.tracers) == 0 {
+		return
+	}
+
+	id := goroutineId()
+	for tracer, goroutine := range miTracers.tracers {
+		if goroutine != id {
+			continue
+		}
+
+		select {
+		case tracer <- cmd:
+		default:
+		}
+	}
+}
+
+// The connection to gdb that every handler goes through. Gdblib doesn't say
+// what it sends so each of its calls is traced here, where godbg makes it,
+// with the MI command that it stands for.
+type tracedGDB struct {
+	*gdblib.GDB
+}
+
+func (g *tracedGDB) ExecArgs(parms gdblib.ExecArgsParms) error {
+	start := time.Now()
+	err := g.GDB.ExecArgs(parms)
+	traceMi("-exec-arguments", start, parms, nil, err)
+	return err
+}
+
+func (g *tracedGDB) ExecRun(parms gdblib.ExecRunParms) error {
+	start := time.Now()
+	err := g.GDB.ExecRun(parms)
+	traceMi("-exec-run", start, parms, nil, err)
+	return err
+}
+
+func (g *tracedGDB) ExecNext(parms gdblib.ExecNextParms) error {
+	start := time.Now()
+	err := g.GDB.ExecNext(parms)
+	traceMi("-exec-next", start, parms, nil, err)
+	return err
+}
+
+func (g *tracedGDB) ExecStep(parms gdblib.ExecStepParms) error {
+	start := time.Now()
+	err := g.GDB.ExecStep(parms)
+	traceMi("-exec-step", start, parms, nil, err)
+	return err
+}
+
+func (g *tracedGDB) ExecContinue(parms gdblib.ExecContinueParms) error {
+	start := time.Now()
+	err := g.GDB.ExecContinue(parms)
+	traceMi("-exec-continue", start, parms, nil, err)
+	return err
+}
+
+func (g *tracedGDB) ExecInterrupt(parms gdblib.ExecInterruptParms) error {
+	start := time.Now()
+	err := g.GDB.ExecInterrupt(parms)
+	traceMi("-exec-interrupt", start, parms, nil, err)
+	return err
+}
+
+func (g *tracedGDB) ThreadListIds() (*gdblib.ThreadListIdsResult, error) {
+	start := time.Now()
+	result, err := g.GDB.ThreadListIds()
+	traceMi("-thread-list-ids", start, nil, result, err)
+	return result, err
+}
+
+func (g *tracedGDB) ThreadSelect(parms gdblib.ThreadSelectParms) (*gdblib.ThreadSelectResult, error) {
+	start := time.Now()
+	result, err := g.GDB.ThreadSelect(parms)
+	traceMi("-thread-select", start, parms, result, err)
+	return result, err
+}
+
+func (g *tracedGDB) ThreadInfo(parms gdblib.ThreadInfoParms) (*gdblib.ThreadInfoResult, error) {
+	start := time.Now()
+	result, err := g.GDB.ThreadInfo(parms)
+	traceMi("-thread-info", start, parms, result, err)
+	return result, err
+}
+
+func (g *tracedGDB) StackInfoFrame() (*gdblib.StackInfoFrameResult, error) {
+	start := time.Now()
+	result, err := g.GDB.StackInfoFrame()
+	traceMi("-stack-info-frame", start, nil, result, err)
+	return result, err
+}
+
+func (g *tracedGDB) StackListFrames(parms gdblib.StackListFramesParms) (*gdblib.StackListFramesResult, error) {
+	start := time.Now()
+	result, err := g.GDB.StackListFrames(parms)
+	traceMi("-stack-list-frames", start, parms, result, err)
+	return result, err
+}
+
+func (g *tracedGDB) StackListVariables(parms gdblib.StackListVariablesParms) (*gdblib.StackListVariablesResult, error) {
+	start := time.Now()
+	result, err := g.GDB.StackListVariables(parms)
+	traceMi("-stack-list-variables", start, parms, result, err)
+	return result, err
+}
+
+func (g *tracedGDB) BreakList() (*gdblib.BreakListResult, error) {
+	start := time.Now()
+	result, err := g.GDB.BreakList()
+	traceMi("-break-list", start, nil, result, err)
+	return result, err
+}
+
+func (g *tracedGDB) BreakInsert(parms gdblib.BreakInsertParms) (*gdblib.BreakInsertResult, error) {
+	start := time.Now()
+	result, err := g.GDB.BreakInsert(parms)
+	traceMi("-break-insert", start, parms, result, err)
+	return result, err
+}
+
+func (g *tracedGDB) BreakEnable(parms gdblib.BreakEnableParms) error {
+	start := time.Now()
+	err := g.GDB.BreakEnable(parms)
+	traceMi("-break-enable", start, parms, nil, err)
+	return err
+}
+
+func (g *tracedGDB) BreakDisable(parms gdblib.BreakDisableParms) error {
+	start := time.Now()
+	err := g.GDB.BreakDisable(parms)
+	traceMi("-break-disable", start, parms, nil, err)
+	return err
+}
+
+func (g *tracedGDB) BreakCommands(parms gdblib.BreakCommandsParms) error {
+	start := time.Now()
+	err := g.GDB.BreakCommands(parms)
+	traceMi("-break-commands", start, parms, nil, err)
+	return err
+}
+
+func (g *tracedGDB) VarCreate(parms gdblib.VarCreateParms) (*gdblib.VarCreateResult, error) {
+	start := time.Now()
+	result, err := g.GDB.VarCreate(parms)
+	traceMi("-var-create", start, parms, result, err)
+	return result, err
+}
+
+func (g *tracedGDB) VarDelete(parms gdblib.VarDeleteParms) error {
+	start := time.Now()
+	err := g.GDB.VarDelete(parms)
+	traceMi("-var-delete", start, parms, nil, err)
+	return err
+}
+
+func (g *tracedGDB) VarListChildren(parms gdblib.VarListChildrenParms) (*gdblib.VarListChildrenResult, error) {
+	start := time.Now()
+	result, err := g.GDB.VarListChildren(parms)
+	traceMi("-var-list-children", start, parms, result, err)
+	return result, err
+}
+
+func (g *tracedGDB) InterpreterExec(parms gdblib.InterpreterExecParms) error {
+	start := time.Now()
+	err := g.GDB.InterpreterExec(parms)
+	traceMi("-interpreter-exec", start, parms, nil, err)
+	return err
+}
+
+func (g *tracedGDB) DataEvaluateExpression(parms gdblib.DataEvaluateExpressionParms) (*gdblib.DataEvaluateExpressionResult, error) {
+	start := time.Now()
+	result, err := g.GDB.DataEvaluateExpression(parms)
+	traceMi("-data-evaluate-expression", start, parms, result, err)
+	return result, err
+}
+
+func (g *tracedGDB) GdbSet(parms gdblib.GdbSetParms) error {
+	start := time.Now()
+	err := g.GDB.GdbSet(parms)
+	traceMi("-gdb-set", start, parms, nil, err)
+	return err
+}
+
+func (g *tracedGDB) GdbShow(parms gdblib.GdbShowParms) (*gdblib.GdbShowResult, error) {
+	start := time.Now()
+	result, err := g.GDB.GdbShow(parms)
+	traceMi("-gdb-show", start, parms, result, err)
+	return result, err
+}
+
+func (g *tracedGDB) ListFeatures() (*gdblib.ListFeaturesResult, error) {
+	start := time.Now()
+	result, err := g.GDB.ListFeatures()
+	traceMi("-list-features", start, nil, result, err)
+	return result, err
+}
+
+func (g *tracedGDB) ListTargetFeatures() (*gdblib.ListFeaturesResult, error) {
+	start := time.Now()
+	result, err := g.GDB.ListTargetFeatures()
+	traceMi("-list-target-features", start, nil, result, err)
+	return result, err
+}
+
+func (g *tracedGDB) DataListRegisterNames(parms gdblib.DataListRegisterNamesParms) (*gdblib.DataListRegisterNamesResult, error) {
+	start := time.Now()
+	result, err := g.GDB.DataListRegisterNames(parms)
+	traceMi("-data-list-register-names", start, parms, result, err)
+	return result, err
+}
+
+func (g *tracedGDB) DataListRegisterValues(parms gdblib.DataListRegisterValuesParms) (*gdblib.DataListRegisterValuesResult, error) {
+	start := time.Now()
+	result, err := g.GDB.DataListRegisterValues(parms)
+	traceMi("-data-list-register-values", start, parms, result, err)
+	return result, err
+}
+
+func (g *tracedGDB) StackListArguments(parms gdblib.StackListArgumentsParms) (*gdblib.StackListArgumentsResult, error) {
+	start := time.Now()
+	result, err := g.GDB.StackListArguments(parms)
+	traceMi("-stack-list-arguments", start, parms, result, err)
+	return result, err
+}
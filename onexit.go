@@ -0,0 +1,63 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/sirnewton01/gdblib"
+	"sync"
+)
+
+// What happens when the program exits, one of stop (nothing more happens),
+// rerun (run it again if it exited cleanly) or exit (shut down godbg)
+var onExit = struct {
+	sync.Mutex
+	action string
+}{action: "stop"}
+
+func getOnExit() string {
+	onExit.Lock()
+	defer onExit.Unlock()
+
+	return onExit.action
+}
+
+func setOnExit(action string) {
+	onExit.Lock()
+	defer onExit.Unlock()
+
+	onExit.action = action
+}
+
+// Carries out the exit action for a stopped record if it is for the program
+// exiting. It is the last thing done after a stop so that the program isn't
+// run again while the on stop commands are still going.
+func (b *broadcaster) handleExit(record gdblib.AsyncResultRecord) {
+	reason, _ := record.Result["reason"].(string)
+	if reason != "exited-normally" && reason != "exited" {
+		return
+	}
+
+	action := getOnExit()
+	exitCode, _ := record.Result["exit-code"].(string)
+
+	switch {
+	case action == "exit":
+		b.mygdb.GdbExit()
+	case action == "rerun" && reason == "exited-normally":
+		err := b.mygdb.ExecRun(gdblib.ExecRunParms{})
+		if err != nil {
+			b.publish(webSockResult{Type: "onexit", Data: struct {
+				Action string
+				Error  string
+			}{"rerun", err.Error()}})
+		}
+	case action == "rerun":
+		// A failing run is left for looking at instead of starting over
+		b.publish(webSockResult{Type: "onexit", Data: struct {
+			Action   string
+			ExitCode string
+		}{"stop", exitCode}})
+	}
+}
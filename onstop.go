@@ -0,0 +1,73 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sync"
+)
+
+// Commands run through the gdb command-line interpreter each time that the
+// program stops
+var onStop = struct {
+	sync.Mutex
+	commands []string
+	// Whether the commands are cleared after the next stop
+	once bool
+}{}
+
+type onStopResult struct {
+	Command string
+	Output  string
+	Error   string
+}
+
+func setOnStop(commands []string, once bool) {
+	onStop.Lock()
+	defer onStop.Unlock()
+
+	onStop.commands = commands
+	onStop.once = once
+}
+
+func getOnStop() ([]string, bool) {
+	onStop.Lock()
+	defer onStop.Unlock()
+
+	return append([]string{}, onStop.commands...), onStop.once
+}
+
+// Returns the commands to run for a stop clearing them if they were only
+// for the next one
+func takeOnStop() []string {
+	onStop.Lock()
+	defer onStop.Unlock()
+
+	commands := onStop.commands
+	if onStop.once {
+		onStop.commands = nil
+	}
+	return commands
+}
+
+// Runs the on stop commands for a stop publishing what they output
+func (b *broadcaster) runOnStop() {
+	commands := takeOnStop()
+	if len(commands) == 0 {
+		return
+	}
+
+	results := []onStopResult{}
+	for _, command := range commands {
+		cliOutput, err := cliExec(b.mygdb, command)
+
+		result := onStopResult{Command: command, Output: cliOutput}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	b.publish(webSockResult{Type: "onstop", Data: results})
+}
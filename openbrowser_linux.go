@@ -7,7 +7,6 @@
 package main
 
 import (
-	"fmt"
 	"os"
 	"os/exec"
 	"syscall"
@@ -16,7 +15,6 @@ import (
 func openBrowser(url string) {
 	if os.Getenv("DISPLAY") == "" {
 		// No display means that the a browser cannot be opened automatically
-		fmt.Printf("%v\n", url)
 		return
 	}
 
@@ -24,7 +22,6 @@ func openBrowser(url string) {
 		// SSH environment variables means that the terminal is running through an secure
 		//  shell session. We want to launch the browser where the display is located,
 		//  not through a X tunnel.
-		fmt.Printf("%v\n", url)
 		return
 	}
 
@@ -32,8 +29,6 @@ func openBrowser(url string) {
 	//  on the local machine using the user's preferred browser.
 	cmd := exec.Command("xdg-open", url)
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
-	err := cmd.Run()
-	if err != nil {
-		fmt.Printf("%v\n", url)
-	}
+	// The URL has already been printed to the console in case this fails
+	cmd.Run()
 }
@@ -7,14 +7,11 @@
 package main
 
 import (
-	"fmt"
 	"os/exec"
 )
 
 func openBrowser(url string) {
 	cmd := exec.Command("cmd", "/c", "start", url)
-	err := cmd.Run()
-	if err != nil {
-		fmt.Printf("%v\n", url)
-	}
+	// The URL has already been printed to the console in case this fails
+	cmd.Run()
 }
@@ -0,0 +1,513 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/base64"
+	"github.com/sirnewton01/gdblib"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Maximum number of messages held for the first client to connect
+	maxPendingMessages = 1000
+	// Number of console and target lines kept for clients that poll for them
+	maxRecentLines = 1000
+)
+
+type webSockResult struct {
+	Type string
+	// How the data is encoded if it is not sent as it is
+	Encoding string `json:",omitempty"`
+	Data     interface{}
+}
+
+// A websocket client with the categories of messages that it has muted
+type outputClient struct {
+	id    int
+	muted map[string]bool
+	// Messages held back while the client has paused its stream and the
+	//  number that didn't fit
+	paused  bool
+	held    []webSockResult
+	dropped int
+	// Messages dropped because the client wasn't reading them fast enough
+	lost int
+}
+
+// A console or target line held in the broadcaster's ring buffer
+type outputLine struct {
+	Seq  int64
+	Type string
+	Data interface{}
+}
+
+// The broadcaster is the only reader of the gdblib output channels. It
+// forwards each message to all of the connected websocket clients and to
+// any console capture in progress.
+type broadcaster struct {
+	mygdb *tracedGDB
+
+	mutex   sync.Mutex
+	clients map[chan webSockResult]*outputClient
+	nextId  int
+	// Messages that arrived before any client connected
+	pending []webSockResult
+	// Console lines of the CLI command currently executing, nil otherwise
+	capture []string
+
+	barrier chan chan bool
+
+	// Ring buffer of the most recent console and target lines
+	recent  []outputLine
+	nextSeq int64
+	// Closed and replaced whenever a line is added to the ring buffer
+	recentChanged chan bool
+
+	// Handlers waiting on the next async records from gdb
+	asyncWatchers map[chan gdblib.AsyncResultRecord]bool
+	// Requests collecting everything that gdb sends while they are handled
+	messageWatchers map[chan webSockResult]bool
+
+	// Stopped records waiting for what is done after each stop, which is
+	//  done for one stop at a time in the order that they happened. The
+	//  counts are for waiting until the stops so far have been handled.
+	stopMutex    sync.Mutex
+	stopCond     *sync.Cond
+	stopQueue    []gdblib.AsyncResultRecord
+	stopsQueued  int
+	stopsHandled int
+}
+
+func newBroadcaster(mygdb *tracedGDB) *broadcaster {
+	b := &broadcaster{mygdb: mygdb, clients: make(map[chan webSockResult]*outputClient),
+		barrier: make(chan chan bool), recentChanged: make(chan bool),
+		asyncWatchers:   make(map[chan gdblib.AsyncResultRecord]bool),
+		messageWatchers: make(map[chan webSockResult]bool)}
+	b.stopCond = sync.NewCond(&b.stopMutex)
+	return b
+}
+
+func (b *broadcaster) run() {
+	go b.handleStops()
+
+	for {
+		select {
+		case data := <-b.mygdb.Console:
+			b.handleConsole(data)
+		case data := <-b.mygdb.Target:
+			b.handleTarget(data)
+		case data := <-b.mygdb.InternalLog:
+			b.publish(webSockResult{Type: "gdb", Data: data})
+		case record := <-b.mygdb.AsyncResults:
+			b.handleRecord(record)
+		case done := <-b.barrier:
+			// Go picks between the ready cases at random so anything that
+			//  was already waiting is handled before the barrier is let go.
+			b.drain()
+			close(done)
+		}
+	}
+}
+
+// Dispatches everything that is waiting on the gdblib channels
+func (b *broadcaster) drain() {
+	for {
+		select {
+		case data := <-b.mygdb.Console:
+			b.handleConsole(data)
+		case data := <-b.mygdb.Target:
+			b.handleTarget(data)
+		case data := <-b.mygdb.InternalLog:
+			b.publish(webSockResult{Type: "gdb", Data: data})
+		case record := <-b.mygdb.AsyncResults:
+			b.handleRecord(record)
+		default:
+			return
+		}
+	}
+}
+
+func (b *broadcaster) handleConsole(data string) {
+	displays.record(data)
+
+	b.mutex.Lock()
+	if b.capture != nil {
+		b.capture = append(b.capture, data)
+	}
+	b.mutex.Unlock()
+
+	b.publish(webSockResult{Type: "console", Data: data})
+}
+
+func (b *broadcaster) handleTarget(data string) {
+	// Encoding the output keeps the bytes exactly as the program wrote them
+	// whatever the client does with JSON strings.
+	if *rawOutput {
+		b.publish(webSockResult{Type: "target-raw", Encoding: "base64",
+			Data: base64.StdEncoding.EncodeToString([]byte(data))})
+	} else {
+		b.publish(webSockResult{Type: "target", Data: data})
+	}
+}
+
+func (b *broadcaster) handleRecord(record gdblib.AsyncResultRecord) {
+	// The stop is queued before anyone waiting on it hears about it so that
+	//  they can wait for it to be handled.
+	if record.Indication == "stopped" {
+		recentStops.record(record)
+		b.queueStop(record)
+	} else if record.Indication == "running" {
+		recentStops.running(record)
+	}
+
+	b.mutex.Lock()
+	for watcher := range b.asyncWatchers {
+		select {
+		case watcher <- record:
+		default:
+		}
+	}
+	b.mutex.Unlock()
+
+	b.publish(webSockResult{Type: "async", Data: record})
+}
+
+func (b *broadcaster) queueStop(record gdblib.AsyncResultRecord) {
+	b.stopMutex.Lock()
+	defer b.stopMutex.Unlock()
+
+	b.stopQueue = append(b.stopQueue, record)
+	b.stopsQueued++
+	b.stopCond.Broadcast()
+}
+
+// Does what comes after each stop, which all needs gdb, so it is kept off of
+// the broadcaster's goroutine: sending the details of the stop, writing it
+// to the stop log, running the on stop commands and then the exit action.
+// The stop log comes before the commands so that it has the values from
+// where the program stopped.
+func (b *broadcaster) handleStops() {
+	for {
+		b.stopMutex.Lock()
+		for len(b.stopQueue) == 0 {
+			b.stopCond.Wait()
+		}
+		record := b.stopQueue[0]
+		b.stopQueue = b.stopQueue[1:]
+		b.stopMutex.Unlock()
+
+		if stopNeedsEnriching() {
+			details := stopDetails(b.mygdb, record, getStopFormat())
+			if details != nil {
+				b.publish(webSockResult{Type: "stop-details", Data: details})
+			}
+		}
+
+		if stopLogFile != nil {
+			b.logStop(record)
+		}
+
+		b.runOnStop()
+		b.handleExit(record)
+
+		b.stopMutex.Lock()
+		b.stopsHandled++
+		b.stopCond.Broadcast()
+		b.stopMutex.Unlock()
+	}
+}
+
+// Waits until everything that comes after the stops so far has been done.
+// Anything that lets the program go again by itself (e.g. continuing until
+// a condition holds) waits for this first so that none of it happens
+// while the program is running.
+func (b *broadcaster) settleStops() {
+	b.stopMutex.Lock()
+	defer b.stopMutex.Unlock()
+
+	queued := b.stopsQueued
+	for b.stopsHandled < queued {
+		b.stopCond.Wait()
+	}
+}
+
+func (b *broadcaster) publish(msg webSockResult) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if msg.Type == "console" || msg.Type == "target" || msg.Type == "target-raw" {
+		if len(b.recent) >= maxRecentLines {
+			b.recent = b.recent[1:]
+		}
+		b.recent = append(b.recent, outputLine{b.nextSeq, msg.Type, msg.Data})
+		b.nextSeq++
+
+		close(b.recentChanged)
+		b.recentChanged = make(chan bool)
+	}
+
+	for watcher := range b.messageWatchers {
+		select {
+		case watcher <- msg:
+		default:
+		}
+	}
+
+	if len(b.clients) == 0 {
+		if len(b.pending) < maxPendingMessages {
+			b.pending = append(b.pending, msg)
+		}
+		return
+	}
+
+	for client, info := range b.clients {
+		if info.muted[msg.Type] {
+			continue
+		}
+
+		if info.paused {
+			if len(info.held) < maxPendingMessages {
+				info.held = append(info.held, msg)
+			} else {
+				info.dropped++
+			}
+			continue
+		}
+
+		// A client that has fallen this far behind is likely gone so the
+		//  message is dropped rather than stalling gdb.
+		select {
+		case client <- msg:
+		default:
+			info.lost++
+			if info.lost == 1 || info.lost%maxPendingMessages == 0 {
+				log.Printf("Dropped %v messages for websocket client %v that isn't keeping up\n", info.lost, info.id)
+			}
+		}
+	}
+}
+
+// Adds a client returning its channel and the id that it is known by for
+// setting up its filter. The pending messages are handed to the first one.
+func (b *broadcaster) subscribe() (chan webSockResult, int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	client := make(chan webSockResult, maxPendingMessages)
+	for _, msg := range b.pending {
+		client <- msg
+	}
+	b.pending = nil
+
+	b.nextId++
+	b.clients[client] = &outputClient{id: b.nextId, muted: make(map[string]bool)}
+	return client, b.nextId
+}
+
+// Replaces the categories of messages that are muted for the client. Returns
+// false if there is no client with the id.
+func (b *broadcaster) setMuted(id int, categories []string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, info := range b.clients {
+		if info.id == id {
+			info.muted = make(map[string]bool)
+			for _, category := range categories {
+				info.muted[category] = true
+			}
+			// The raw program output is the same category as the rest of it
+			info.muted["target-raw"] = info.muted["target"]
+			return true
+		}
+	}
+
+	return false
+}
+
+// Holds back the messages for the client until it resumes. Returns false if
+// there is no client with the id.
+func (b *broadcaster) pause(id int) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, info := range b.clients {
+		if info.id == id {
+			info.paused = true
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sends the client the messages that were held back while it was paused
+// returning how many there were and how many had to be dropped
+func (b *broadcaster) resume(id int) (int, int, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for client, info := range b.clients {
+		if info.id != id {
+			continue
+		}
+
+		delivered, dropped := 0, info.dropped
+		for _, msg := range info.held {
+			select {
+			case client <- msg:
+				delivered++
+			default:
+				dropped++
+			}
+		}
+
+		info.paused = false
+		info.held = nil
+		info.dropped = 0
+		return delivered, dropped, true
+	}
+
+	return 0, 0, false
+}
+
+func (b *broadcaster) unsubscribe(client chan webSockResult) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.clients, client)
+}
+
+// Waits until every message that gdblib has handed to the broadcaster so far
+// has been dispatched.
+func (b *broadcaster) flush() {
+	done := make(chan bool)
+	b.barrier <- done
+	<-done
+}
+
+func (b *broadcaster) startCapture() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.capture = []string{}
+}
+
+func (b *broadcaster) stopCapture() []string {
+	// Gdb writes all of the console lines for a command before its result
+	//  so once the command returns they only need to make it through the
+	//  broadcaster.
+	b.flush()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	lines := b.capture
+	b.capture = nil
+	return lines
+}
+
+var cliMutex sync.Mutex
+
+// Executes a command through the gdb command-line interpreter returning the
+// console output that it produced. Only one command runs at a time so
+// that the output of one is not mixed up with another.
+func cliExec(mygdb *tracedGDB, command string) (string, error) {
+	cliMutex.Lock()
+	defer cliMutex.Unlock()
+
+	output.startCapture()
+	err := mygdb.InterpreterExec(gdblib.InterpreterExecParms{Interpreter: "console", Command: command})
+	lines := output.stopCapture()
+
+	return strings.Join(lines, ""), err
+}
+
+// Returns the buffered lines starting at the given sequence number waiting up
+// to the timeout for one to arrive if there are none yet. The sequence number
+// to continue from is returned with the lines.
+func (b *broadcaster) tail(since int64, timeout time.Duration) ([]outputLine, int64) {
+	deadline := time.After(timeout)
+
+	for {
+		b.mutex.Lock()
+		lines := []outputLine{}
+		for _, line := range b.recent {
+			if line.Seq >= since {
+				lines = append(lines, line)
+			}
+		}
+		next := b.nextSeq
+		changed := b.recentChanged
+		b.mutex.Unlock()
+
+		if len(lines) > 0 || since > next {
+			return lines, next
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return lines, next
+		}
+	}
+}
+
+// Starts handing a copy of each async record to the returned channel until
+// unwatchAsync is called. Records are dropped if the channel falls behind.
+func (b *broadcaster) watchAsync() chan gdblib.AsyncResultRecord {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	watcher := make(chan gdblib.AsyncResultRecord, 100)
+	b.asyncWatchers[watcher] = true
+	return watcher
+}
+
+func (b *broadcaster) unwatchAsync(watcher chan gdblib.AsyncResultRecord) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.asyncWatchers, watcher)
+}
+
+// Starts handing a copy of each message that is published to the returned
+// channel until unwatchMessages is called. Messages are dropped if the
+// channel falls behind.
+func (b *broadcaster) watchMessages() chan webSockResult {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	watcher := make(chan webSockResult, maxPendingMessages)
+	b.messageWatchers[watcher] = true
+	return watcher
+}
+
+func (b *broadcaster) unwatchMessages(watcher chan webSockResult) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.messageWatchers, watcher)
+}
+
+// Waits up to the timeout for a stopped record to arrive on the watcher
+func waitForStop(watcher chan gdblib.AsyncResultRecord, timeout time.Duration) (gdblib.AsyncResultRecord, bool) {
+	deadline := time.After(timeout)
+
+	for {
+		select {
+		case record := <-watcher:
+			if record.Indication == "stopped" {
+				return record, true
+			}
+		case <-deadline:
+			return gdblib.AsyncResultRecord{}, false
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Matches the version in a module cache directory (e.g. "@v1.2.3")
+var moduleVersionPattern = regexp.MustCompile(`@[^/]+`)
+
+// A node of the source file tree. Packages are nested under the leading
+// parts of their import path (e.g. "github.com" then "sirnewton01").
+type packageNode struct {
+	Name     string
+	Package  string         `json:",omitempty"`
+	Dir      string         `json:",omitempty"`
+	Files    []string       `json:",omitempty"`
+	Children []*packageNode `json:",omitempty"`
+}
+
+// Parses the absolute file names out of the info sources output, which lists
+// them separated by commas under headings that differ between versions of
+// gdb.
+func parseSourceFiles(cliOutput string) []string {
+	seen := make(map[string]bool)
+	files := []string{}
+
+	for _, line := range strings.Split(cliOutput, "\n") {
+		for _, field := range strings.Split(line, ",") {
+			field = strings.TrimSpace(field)
+			if !filepath.IsAbs(field) || strings.HasSuffix(field, ":") || seen[field] {
+				continue
+			}
+
+			seen[field] = true
+			files = append(files, field)
+		}
+	}
+
+	sort.Strings(files)
+	return files
+}
+
+// Works out the import path of the package in the directory from where it
+// is under the GOPATH, the GOROOT or the module cache. Directories that
+// aren't in any of them are kept as they are.
+func importPath(dir string) string {
+	roots := []string{filepath.Join(goroot, "src")}
+	for _, path := range gopaths {
+		roots = append(roots, filepath.Join(path, "pkg", "mod"), filepath.Join(path, "src"))
+	}
+
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, dir)
+		if err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			return moduleVersionPattern.ReplaceAllString(filepath.ToSlash(rel), "")
+		}
+	}
+
+	return filepath.ToSlash(dir)
+}
+
+func packageTree(files []string) *packageNode {
+	root := &packageNode{}
+
+	byDir := make(map[string][]string)
+	dirs := []string{}
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		if byDir[dir] == nil {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], filepath.Base(file))
+	}
+
+	for _, dir := range dirs {
+		pkg := importPath(dir)
+
+		node := root
+		for _, part := range strings.Split(strings.Trim(pkg, "/"), "/") {
+			var child *packageNode
+			for _, c := range node.Children {
+				if c.Name == part {
+					child = c
+					break
+				}
+			}
+			if child == nil {
+				child = &packageNode{Name: part}
+				node.Children = append(node.Children, child)
+			}
+			node = child
+		}
+
+		node.Package = pkg
+		node.Dir = dir
+		node.Files = append(node.Files, byDir[dir]...)
+	}
+
+	return root
+}
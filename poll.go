@@ -0,0 +1,186 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/sirnewton01/gdblib"
+	"sync"
+	"time"
+)
+
+const (
+	// How often the expression is looked at when no interval is given and
+	// the shortest interval allowed
+	defaultPollInterval = time.Second
+	minPollInterval     = 100 * time.Millisecond
+)
+
+type pollSettings struct {
+	Expression string
+	// Appended to the expression (e.g. "!= 0"), without a condition a hit
+	//  is when the value changes
+	Condition string
+	Interval  string
+}
+
+// The expression that is being polled while the program runs, there is at
+// most one of them at a time
+var poller = struct {
+	sync.Mutex
+	settings pollSettings
+	stop     chan bool
+}{}
+
+func getPoll() (pollSettings, bool) {
+	poller.Lock()
+	defer poller.Unlock()
+
+	return poller.settings, poller.stop != nil
+}
+
+func startPoll(mygdb *tracedGDB, settings pollSettings, interval time.Duration) {
+	poller.Lock()
+	defer poller.Unlock()
+
+	if poller.stop != nil {
+		close(poller.stop)
+	}
+
+	poller.settings = settings
+	poller.stop = make(chan bool)
+	go runPoll(mygdb, settings, interval, poller.stop)
+}
+
+func stopPoll() {
+	poller.Lock()
+	defer poller.Unlock()
+
+	if poller.stop != nil {
+		close(poller.stop)
+		poller.stop = nil
+	}
+	poller.settings = pollSettings{}
+}
+
+// Forgets the poll after it was hit unless another one has replaced it
+func finishPoll(stop chan bool) {
+	poller.Lock()
+	defer poller.Unlock()
+
+	if poller.stop == stop {
+		poller.stop = nil
+		poller.settings = pollSettings{}
+	}
+}
+
+// Whether any of the program's threads are running
+func programRunning(mygdb *tracedGDB) bool {
+	infoResult, err := mygdb.ThreadInfo(gdblib.ThreadInfoParms{})
+	if err != nil {
+		return false
+	}
+
+	info := struct {
+		Threads []map[string]interface{} `json:"threads"`
+	}{}
+
+	if remarshal(infoResult, &info) != nil {
+		return false
+	}
+
+	for _, thread := range info.Threads {
+		if threadInState(thread, "running") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Whether the program stopped because it was interrupted rather than for
+// something else that happened at the same time (e.g. a breakpoint)
+func interruptedStop(record gdblib.AsyncResultRecord) bool {
+	reason, _ := record.Result["reason"].(string)
+	signal, _ := record.Result["signal-name"].(string)
+
+	// Remote targets report the interrupt with other signals
+	return reason == "" ||
+		(reason == "signal-received" && (signal == "SIGINT" || signal == "SIGTRAP" || signal == "0"))
+}
+
+// Interrupts the program at each interval to evaluate the expression and
+// lets it carry on unless the condition holds. Each interrupt is a full stop
+// of the program so this changes its timing a lot, it is only for when
+// there is no watchpoint that can do the same. Nothing is done while the
+// program is stopped.
+func runPoll(mygdb *tracedGDB, settings pollSettings, interval time.Duration, stop chan bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	previous := ""
+	sampled := false
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if !programRunning(mygdb) {
+			continue
+		}
+
+		watcher := output.watchAsync()
+		var err error
+		recentStops.expectInterrupt()
+		if *nonStop {
+			_, err = cliExec(mygdb, "interrupt -a")
+		} else {
+			err = mygdb.ExecInterrupt(gdblib.ExecInterruptParms{})
+		}
+		record, stopped := waitForStop(watcher, interruptStopTimeout)
+		output.unwatchAsync(watcher)
+
+		// A stop for any other reason is left for the user to look at
+		if err != nil || !stopped || !interruptedStop(record) {
+			continue
+		}
+
+		hit := false
+		value, err := evaluate(mygdb, settings.Expression)
+		if err == nil && settings.Condition != "" {
+			holds, err := evaluate(mygdb, "("+settings.Expression+") "+settings.Condition)
+			hit = err == nil && valueIsTrue(holds)
+		} else if err == nil {
+			hit = sampled && value != previous
+			previous, sampled = value, true
+		}
+
+		if hit {
+			finishPoll(stop)
+			output.publish(webSockResult{Type: "poll-hit", Data: struct {
+				Expression string
+				Condition  string
+				Value      string
+			}{settings.Expression, settings.Condition, value}})
+			return
+		}
+
+		select {
+		case <-stop:
+			// The program is left stopped if polling was stopped meanwhile
+			return
+		default:
+		}
+
+		output.settleStops()
+		if *nonStop {
+			cliExec(mygdb, "continue -a")
+		} else {
+			mygdb.ExecContinue(gdblib.ExecContinueParms{})
+		}
+	}
+}
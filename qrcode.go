@@ -0,0 +1,37 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"rsc.io/qr"
+)
+
+// Prints the QR code with a quiet zone using the terminal's white and black
+// background colours so that it scans on both light and dark terminals.
+func printQRCode(text string) {
+	code, err := qr.Encode(text, qr.L)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	const quietZone = 4
+	const light = "\033[47m  \033[0m"
+	const dark = "\033[40m  \033[0m"
+
+	for y := -quietZone; y < code.Size+quietZone; y++ {
+		line := ""
+		for x := -quietZone; x < code.Size+quietZone; x++ {
+			// The code is light outside of its size
+			if code.Black(x, y) {
+				line += dark
+			} else {
+				line += light
+			}
+		}
+		fmt.Printf("%v\n", line)
+	}
+}
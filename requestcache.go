@@ -0,0 +1,167 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// Header that clients set to make retrying a request safe
+	requestIdHeader = "X-Request-Id"
+	// How long the result of a request is kept for its retries
+	requestCacheExpiry = 60 * time.Second
+	// How long a request that never finishes (e.g. a handler that is stuck
+	//  on gdb) holds on to its id
+	requestPendingExpiry = 10 * time.Minute
+	// Most results kept at once, the ones that expire soonest make room
+	maxCachedResults = 1000
+	// Looking up a result doesn't count as a request to cache
+	requestResultPath = "/handle/gdb/result"
+)
+
+type cachedResult struct {
+	done    bool
+	status  int
+	body    []byte
+	expires time.Time
+}
+
+// Results of the requests that carried a request id so that a client that
+// lost the response can get it again without running the command twice.
+type requestCache struct {
+	mutex   sync.Mutex
+	results map[string]*cachedResult
+}
+
+var requestResults = requestCache{results: make(map[string]*cachedResult)}
+
+// Claims the request id for a new request. If the id has already been used
+// the earlier result is returned instead, which isn't done yet if the
+// request is still being handled.
+func (c *requestCache) start(id string) (cachedResult, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.expire()
+
+	if result, ok := c.results[id]; ok {
+		return *result, false
+	}
+
+	c.makeRoom()
+	c.results[id] = &cachedResult{expires: time.Now().Add(requestPendingExpiry)}
+	return cachedResult{}, true
+}
+
+func (c *requestCache) finish(id string, status int, body []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// The request may have been forgotten while it was being handled
+	if _, ok := c.results[id]; !ok {
+		c.makeRoom()
+	}
+	c.results[id] = &cachedResult{true, status, body, time.Now().Add(requestCacheExpiry)}
+}
+
+func (c *requestCache) get(id string) (cachedResult, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.expire()
+
+	result, ok := c.results[id]
+	if !ok {
+		return cachedResult{}, false
+	}
+
+	return *result, true
+}
+
+// Forgets the results that have expired. The mutex must be held.
+func (c *requestCache) expire() {
+	now := time.Now()
+	for key, result := range c.results {
+		if now.After(result.expires) {
+			delete(c.results, key)
+		}
+	}
+}
+
+// Forgets the results that expire soonest until there is room for another
+// one. The mutex must be held.
+func (c *requestCache) makeRoom() {
+	for len(c.results) >= maxCachedResults {
+		soonest := ""
+		for key, result := range c.results {
+			if soonest == "" || result.expires.Before(c.results[soonest].expires) {
+				soonest = key
+			}
+		}
+		delete(c.results, soonest)
+	}
+}
+
+// Captures the full status and body of a response as it is written
+type cachingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *cachingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cachingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = 200
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Writes a result that was cached for a request id
+func writeCachedResult(w http.ResponseWriter, result cachedResult) {
+	if !result.done {
+		w.WriteHeader(202)
+		w.Write([]byte("The request is still being handled"))
+		return
+	}
+
+	w.WriteHeader(result.status)
+	w.Write(result.body)
+}
+
+// Handles the request at most once for each request id that it carries
+func handleOnce(w http.ResponseWriter, r *http.Request, delegate handlerFunc) {
+	id := r.Header.Get(requestIdHeader)
+	if id == "" || r.URL.Path == requestResultPath {
+		delegate(w, r)
+		return
+	}
+
+	earlier, first := requestResults.start(id)
+	if !first {
+		writeCachedResult(w, earlier)
+		return
+	}
+
+	recorder := &cachingResponseWriter{ResponseWriter: w}
+	delegate(recorder, r)
+
+	status := recorder.status
+	if status == 0 {
+		status = 200
+	}
+	requestResults.finish(id, status, recorder.body.Bytes())
+}
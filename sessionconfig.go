@@ -0,0 +1,276 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/sirnewton01/gdblib"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Gdb settings that are carried along with a session's configuration
+var sessionSettings = []string{"print elements", "print pretty", "print object",
+	"print static-members", "print union", "disassembly-flavor",
+	"print address", "print symbol", "follow-fork-mode", "detach-on-fork",
+	"step-mode"}
+
+// The commands that save breakpoints writes to create the breakpoints, which
+// are all that a session's configuration may source. Anything inside a
+// commands block is left alone, which is why applying a configuration needs
+// -allow-write.
+var sessionBreakpointCommands = map[string]bool{"break": true, "tbreak": true, "hbreak": true,
+	"thbreak": true, "rbreak": true, "watch": true, "rwatch": true, "awatch": true, "catch": true,
+	"tcatch": true, "dprintf": true, "condition": true, "ignore": true, "enable": true,
+	"disable": true, "commands": true}
+
+// Matches a rule of the show substitute-path output
+// (e.g. "  `/build/src' -> `/home/user/src'.")
+var substitutePathPattern = regexp.MustCompile("`(.*)' -> `(.*)'")
+
+type substitutePath struct {
+	From string
+	To   string
+}
+
+// The note of a breakpoint in a session's configuration. The breakpoints are
+// numbered again when they are created so the note is kept by the location
+// of the breakpoint instead of its number.
+type sessionNote struct {
+	Location string
+	Note     string
+}
+
+// Where a breakpoint is set as it was asked for, which stays the same when
+// the breakpoint is created again
+func noteLocation(breakpoint breakpointInfo) string {
+	switch {
+	case breakpoint.OriginalLocation != "":
+		return breakpoint.OriginalLocation
+	case breakpoint.File != "" && breakpoint.Line != "":
+		return breakpoint.File + ":" + breakpoint.Line
+	case breakpoint.Func != "":
+		return breakpoint.Func
+	case breakpoint.What != "":
+		return breakpoint.What
+	}
+	return breakpoint.Addr
+}
+
+// Everything needed to set up the same debugging session again. The
+// breakpoints, watchpoints and catchpoints are kept as the gdb commands
+// that create them along with their conditions and commands.
+type sessionConfig struct {
+	Breakpoints     []string
+	Notes           []sessionNote
+	Displays        []string
+	SubstitutePaths []substitutePath
+	Settings        map[string]string
+}
+
+func exportSessionConfig(mygdb *tracedGDB) (*sessionConfig, error) {
+	config := &sessionConfig{Breakpoints: []string{}, Notes: []sessionNote{}, Displays: []string{},
+		SubstitutePaths: []substitutePath{}, Settings: make(map[string]string)}
+
+	file, err := ioutil.TempFile("", "godbg-breakpoints")
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	cliOutput, err := cliExec(mygdb, "save breakpoints "+file.Name())
+	if err != nil && !strings.Contains(cliOutput+err.Error(), "Nothing to save") {
+		return nil, err
+	}
+
+	if err == nil {
+		contents, err := ioutil.ReadFile(file.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, line := range strings.Split(string(contents), "\n") {
+			if strings.TrimSpace(line) != "" {
+				config.Breakpoints = append(config.Breakpoints, line)
+			}
+		}
+	}
+
+	breakpoints, err := breakList(mygdb)
+	if err != nil {
+		return nil, err
+	}
+	for _, breakpoint := range breakpoints {
+		number, _ := strconv.Atoi(breakpoint.Number)
+		if note := getBreakpointNote(number); note != "" {
+			config.Notes = append(config.Notes, sessionNote{noteLocation(breakpoint), note})
+		}
+	}
+
+	cliOutput, err = cliExec(mygdb, "info display")
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(cliOutput, "\n") {
+		match := displayInfoPattern.FindStringSubmatch(line)
+		if match != nil {
+			config.Displays = append(config.Displays, strings.TrimSpace(match[3]))
+		}
+	}
+
+	cliOutput, err = cliExec(mygdb, "show substitute-path")
+	if err != nil {
+		return nil, err
+	}
+	for _, match := range substitutePathPattern.FindAllStringSubmatch(cliOutput, -1) {
+		config.SubstitutePaths = append(config.SubstitutePaths, substitutePath{match[1], match[2]})
+	}
+
+	for _, setting := range sessionSettings {
+		result, err := mygdb.GdbShow(gdblib.GdbShowParms{Variable: setting})
+
+		// Not every setting exists in every version of gdb
+		if err == nil {
+			config.Settings[setting] = result.Value
+		}
+	}
+
+	return config, nil
+}
+
+// Checks that the configuration only creates breakpoints and changes the
+// settings that are carried along with a session, returning the problems
+// found. Nothing should be applied unless there are none.
+func checkSessionConfig(config *sessionConfig) []string {
+	problems := []string{}
+
+	inCommands := false
+	for _, line := range strings.Split(strings.Join(config.Breakpoints, "\n"), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch {
+		case inCommands:
+			inCommands = fields[0] != "end"
+		case sessionBreakpointCommands[fields[0]]:
+			inCommands = fields[0] == "commands"
+		default:
+			problems = append(problems, "Not a breakpoint command: "+line)
+		}
+	}
+
+	for setting := range config.Settings {
+		known := false
+		for _, sessionSetting := range sessionSettings {
+			known = known || setting == sessionSetting
+		}
+		if !known {
+			problems = append(problems, "Not a session setting: "+setting)
+		}
+	}
+
+	// Each of these becomes part of a single command
+	for _, path := range config.SubstitutePaths {
+		if strings.ContainsAny(path.From+path.To, "\r\n") {
+			problems = append(problems, "Not a single line: "+path.From+" "+path.To)
+		}
+	}
+	for _, expression := range config.Displays {
+		if strings.ContainsAny(expression, "\r\n") {
+			problems = append(problems, "Not a single line: "+expression)
+		}
+	}
+	for _, value := range config.Settings {
+		if strings.ContainsAny(value, "\r\n") {
+			problems = append(problems, "Not a single line: "+value)
+		}
+	}
+
+	return problems
+}
+
+// Replaces the current breakpoints, displays and substitutions with the ones
+// in the configuration and applies its settings. Applying carries on past
+// the parts that fail, returning the problems found.
+func applySessionConfig(mygdb *tracedGDB, config *sessionConfig) []string {
+	problems := []string{}
+	report := func(err error) {
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	_, err := cliExec(mygdb, "delete")
+	report(err)
+	breakpointNotesMutex.Lock()
+	breakpointNotes = make(map[int]string)
+	breakpointNotesMutex.Unlock()
+	_, err = cliExec(mygdb, "undisplay")
+	report(err)
+	displays.removeAll()
+	_, err = cliExec(mygdb, "unset substitute-path")
+	report(err)
+
+	for setting, value := range config.Settings {
+		report(mygdb.GdbSet(gdblib.GdbSetParms{Variable: setting, Value: value}))
+	}
+
+	for _, path := range config.SubstitutePaths {
+		_, err = cliExec(mygdb, "set substitute-path "+path.From+" "+path.To)
+		report(err)
+	}
+
+	// The breakpoints are sourced as a script since their commands span
+	//  several lines.
+	if len(config.Breakpoints) > 0 {
+		file, err := ioutil.TempFile("", "godbg-breakpoints")
+		if err != nil {
+			report(err)
+		} else {
+			_, err = file.WriteString(strings.Join(config.Breakpoints, "\n") + "\n")
+			file.Close()
+			report(err)
+
+			if err == nil {
+				_, err = cliExec(mygdb, "source "+file.Name())
+				report(err)
+			}
+			os.Remove(file.Name())
+		}
+	}
+
+	if len(config.Notes) > 0 {
+		breakpoints, err := breakList(mygdb)
+		report(err)
+
+		// Several breakpoints can share a location so each note goes to the
+		//  first one there that doesn't have a note yet.
+		noted := make(map[string]bool)
+		for _, note := range config.Notes {
+			for _, breakpoint := range breakpoints {
+				if noted[breakpoint.Number] || noteLocation(breakpoint) != note.Location {
+					continue
+				}
+
+				number, _ := strconv.Atoi(breakpoint.Number)
+				setBreakpointNote(number, note.Note)
+				noted[breakpoint.Number] = true
+				break
+			}
+		}
+	}
+
+	for _, expression := range config.Displays {
+		_, err = cliExec(mygdb, "display "+expression)
+		report(err)
+	}
+
+	return problems
+}
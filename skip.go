@@ -0,0 +1,107 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Matches a line of the info skip output
+// (e.g. "1     y      n <none>                y ^runtime\.")
+var skipInfoPattern = regexp.MustCompile(`^([0-9]+)\s+([yn])\s+([yn])\s+(\S+)\s+([yn])\s+(.*?)\s*$`)
+
+// Matches a line of the info skip output from versions of gdb before 7.12,
+// which can only skip whole files or functions by name
+// (e.g. "1       function       y   runtime.morestack")
+var oldSkipInfoPattern = regexp.MustCompile(`^([0-9]+)\s+(file|function)\s+([yn])\s+(.*?)\s*$`)
+
+// A rule for what is skipped over when stepping
+type skipRule struct {
+	Number   int
+	Enabled  bool
+	Glob     bool
+	File     string `json:",omitempty"`
+	Regexp   bool
+	Function string `json:",omitempty"`
+}
+
+func listSkips(mygdb *tracedGDB) ([]skipRule, error) {
+	cliOutput, err := cliExec(mygdb, "info skip")
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []skipRule{}
+	for _, line := range strings.Split(cliOutput, "\n") {
+		if match := skipInfoPattern.FindStringSubmatch(line); match != nil {
+			rule := skipRule{Enabled: match[2] == "y", Glob: match[3] == "y", Regexp: match[5] == "y"}
+			rule.Number, _ = strconv.Atoi(match[1])
+			if match[4] != "<none>" {
+				rule.File = match[4]
+			}
+			if match[6] != "<none>" {
+				rule.Function = match[6]
+			}
+			rules = append(rules, rule)
+		} else if match := oldSkipInfoPattern.FindStringSubmatch(line); match != nil {
+			rule := skipRule{Enabled: match[3] == "y"}
+			rule.Number, _ = strconv.Atoi(match[1])
+			if match[2] == "file" {
+				rule.File = match[4]
+			} else {
+				rule.Function = match[4]
+			}
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, nil
+}
+
+// The function regular expression that skips all of the functions of a Go
+// package (e.g. "^net/http\.")
+func packageSkipPattern(pkg string) string {
+	return "^" + regexp.QuoteMeta(pkg) + `\.`
+}
+
+// Adds or removes the rules that skip the functions of the packages leaving
+// any other rules alone
+func setPackageSkips(mygdb *tracedGDB, packages []string, enabled bool) error {
+	rules, err := listSkips(mygdb)
+	if err != nil {
+		return err
+	}
+
+	for _, pkg := range packages {
+		pattern := packageSkipPattern(pkg)
+
+		existing := []int{}
+		for _, rule := range rules {
+			if rule.Regexp && rule.Function == pattern {
+				existing = append(existing, rule.Number)
+			}
+		}
+
+		if enabled && len(existing) == 0 {
+			_, err = cliExec(mygdb, "skip -rfunction "+pattern)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !enabled {
+			for _, number := range existing {
+				_, err = cliExec(mygdb, "skip delete "+strconv.Itoa(number))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
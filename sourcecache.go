@@ -0,0 +1,92 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+type cachedSource struct {
+	modTime  time.Time
+	contents []byte
+	lastUsed time.Time
+}
+
+// Source files that have been read recently so that the same ones aren't
+// read from disk each time that the program stops. A file is read again
+// once it has been modified.
+type sourceCache struct {
+	mutex sync.Mutex
+	files map[string]*cachedSource
+	size  int64
+}
+
+var sources = sourceCache{files: make(map[string]*cachedSource)}
+
+func sourceCacheLimit() int64 {
+	return int64(*sourceCacheMb) << 20
+}
+
+// Returns the contents of the source file at the (already checked) path
+func (c *sourceCache) read(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	cached := c.files[path]
+	if cached != nil && cached.modTime.Equal(info.ModTime()) && int64(len(cached.contents)) == info.Size() {
+		cached.lastUsed = time.Now()
+		c.mutex.Unlock()
+		return cached.contents, nil
+	}
+	c.mutex.Unlock()
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := sourceCacheLimit()
+	if int64(len(contents)) > limit {
+		return contents, nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if old := c.files[path]; old != nil {
+		c.size -= int64(len(old.contents))
+	}
+	c.files[path] = &cachedSource{info.ModTime(), contents, time.Now()}
+	c.size += int64(len(contents))
+
+	// The files that were used the longest ago make room for the new one
+	for c.size > limit {
+		oldestPath := ""
+		for cachedPath, file := range c.files {
+			if oldestPath == "" || file.lastUsed.Before(c.files[oldestPath].lastUsed) {
+				oldestPath = cachedPath
+			}
+		}
+
+		c.size -= int64(len(c.files[oldestPath].contents))
+		delete(c.files, oldestPath)
+	}
+
+	return contents, nil
+}
+
+func (c *sourceCache) clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.files = make(map[string]*cachedSource)
+	c.size = 0
+}
@@ -0,0 +1,239 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/sirnewton01/gdblib"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// Lines of source shown on either side of the line where the program
+	// stopped
+	stopSourceContext = 5
+	// Instructions shown starting at the address where the program stopped
+	stopInstructions = 10
+)
+
+// What is sent to the websocket clients after each stopped event, one of
+// none, source, disassembly or both
+var stopFormat = struct {
+	sync.Mutex
+	mode string
+}{mode: "none"}
+
+// How much of each frame is sent after the stopped events, either location
+// (nothing more than gdb reports) or arguments (the arguments of every frame)
+var frameVerbosity = struct {
+	sync.Mutex
+	level string
+}{level: "location"}
+
+func getFrameVerbosity() string {
+	frameVerbosity.Lock()
+	defer frameVerbosity.Unlock()
+
+	return frameVerbosity.level
+}
+
+func setFrameVerbosity(level string) {
+	frameVerbosity.Lock()
+	defer frameVerbosity.Unlock()
+
+	frameVerbosity.level = level
+}
+
+// Whether anything needs to be sent after the stopped events
+func stopNeedsEnriching() bool {
+	return getStopFormat() != "none" || getFrameVerbosity() == "arguments"
+}
+
+func getStopFormat() string {
+	stopFormat.Lock()
+	defer stopFormat.Unlock()
+
+	return stopFormat.mode
+}
+
+func setStopFormat(mode string) {
+	stopFormat.Lock()
+	defer stopFormat.Unlock()
+
+	stopFormat.mode = mode
+}
+
+type stopSource struct {
+	File      string
+	Line      int
+	StartLine int
+	Lines     []string
+}
+
+type stopInstruction struct {
+	Address     string
+	Function    string
+	Instruction string
+	IsCurrentPC bool
+}
+
+// Looks up the source and/or the disassembly around where the program
+// stopped along with the frame arguments. They are sent as a stop-details
+// message after the stopped record itself so that the record isn't held up
+// until gdb has answered. Anything that can't be found (e.g. source for a
+// library without debug information) is left out.
+func stopDetails(mygdb *tracedGDB, record gdblib.AsyncResultRecord, mode string) map[string]interface{} {
+	frame, ok := record.Result["frame"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	thread, _ := record.Result["thread-id"].(string)
+	details := map[string]interface{}{"thread-id": thread, "frame": frame}
+
+	if mode == "source" || mode == "both" {
+		source := stopSourceLines(frame)
+		if source != nil {
+			details["source"] = source
+		}
+	}
+
+	if mode == "disassembly" || mode == "both" {
+		addr, _ := frame["addr"].(string)
+		instructions := stopDisassembly(mygdb, addr)
+		if instructions != nil {
+			details["disassembly"] = instructions
+		}
+	}
+
+	if getFrameVerbosity() == "arguments" {
+		arguments := stopArguments(mygdb, thread)
+		if arguments != nil {
+			details["arguments"] = arguments
+		}
+	}
+
+	return details
+}
+
+func stopSourceLines(frame map[string]interface{}) *stopSource {
+	path, _ := frame["fullname"].(string)
+	if path == "" {
+		path, _ = frame["file"].(string)
+	}
+	lineStr, _ := frame["line"].(string)
+
+	line, err := strconv.Atoi(lineStr)
+	if path == "" || err != nil {
+		return nil
+	}
+
+	path, err = sourceFilePath(path)
+	if err != nil {
+		return nil
+	}
+
+	contents, err := sources.read(path)
+	if err != nil {
+		return nil
+	}
+
+	lines := strings.Split(string(contents), "\n")
+
+	start := line - stopSourceContext
+	if start < 1 {
+		start = 1
+	}
+	end := line + stopSourceContext
+	if end > len(lines) {
+		end = len(lines)
+	}
+	if start > end {
+		start = end
+	}
+
+	return &stopSource{path, line, start, lines[start-1 : end]}
+}
+
+func stopDisassembly(mygdb *tracedGDB, addr string) []stopInstruction {
+	if !addressPattern.MatchString(addr) {
+		return nil
+	}
+
+	cliOutput, err := cliExec(mygdb, "x/"+strconv.Itoa(stopInstructions)+"i "+addr)
+	if err != nil {
+		return nil
+	}
+
+	instructions := []stopInstruction{}
+	for _, line := range strings.Split(cliOutput, "\n") {
+		match := disassemblyPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		instructions = append(instructions, stopInstruction{match[2], match[3], match[4], match[1] == "=>"})
+	}
+
+	return instructions
+}
+
+type stopFrameArguments struct {
+	Level     string
+	Func      string
+	Arguments []interface{}
+}
+
+// Lists the arguments of each frame of the thread that stopped, which can
+// take a while on a deep stack.
+func stopArguments(mygdb *tracedGDB, thread string) []stopFrameArguments {
+	stackResult, err := mygdb.StackListFrames(gdblib.StackListFramesParms{ThreadId: thread})
+	if err != nil {
+		return nil
+	}
+
+	stack := struct {
+		Stack []backtraceFrame `json:"stack"`
+	}{}
+
+	if remarshal(stackResult, &stack) != nil || len(stack.Stack) == 0 {
+		return nil
+	}
+
+	// The arguments of all of the frames come back from one command
+	argsResult, err := mygdb.StackListArguments(gdblib.StackListArgumentsParms{Thread: thread,
+		AllValues: true, LowFrame: 0, HighFrame: len(stack.Stack) - 1})
+	if err != nil {
+		return nil
+	}
+
+	args := struct {
+		StackArgs []struct {
+			Level string        `json:"level"`
+			Args  []interface{} `json:"args"`
+		} `json:"stack-args"`
+	}{}
+
+	if remarshal(argsResult, &args) != nil {
+		return nil
+	}
+
+	arguments := make(map[string][]interface{})
+	for _, frame := range args.StackArgs {
+		arguments[frame.Level] = frame.Args
+	}
+
+	frames := []stopFrameArguments{}
+	for _, frame := range stack.Stack {
+		frameArguments := arguments[frame.Level]
+		if frameArguments == nil {
+			frameArguments = []interface{}{}
+		}
+		frames = append(frames, stopFrameArguments{frame.Level, frame.Func, frameArguments})
+	}
+
+	return frames
+}
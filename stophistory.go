@@ -0,0 +1,135 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/sirnewton01/gdblib"
+	"sync"
+)
+
+const (
+	// Number of the most recent stop locations that are kept
+	maxStopHistory = 100
+)
+
+type stopLocation struct {
+	Func   string
+	File   string
+	Line   string
+	Addr   string
+	Thread string
+	Reason string
+	// The signal that stopped the program, if that is what did
+	Signal        string `json:",omitempty"`
+	SignalMeaning string `json:",omitempty"`
+	// Godbg interrupted the program itself (e.g. to poll an expression)
+	Interrupted bool `json:",omitempty"`
+}
+
+// Where the program has stopped, oldest first, so that the path taken through
+// it can be looked back over
+type stopHistory struct {
+	mutex     sync.Mutex
+	locations []stopLocation
+	// Where the program is stopped right now, there is nothing while it
+	//  runs or after it exited
+	current *stopLocation
+	// Set from when godbg interrupts the program until it runs again
+	interrupting bool
+}
+
+var recentStops = stopHistory{locations: []stopLocation{}}
+
+// Pulls the location out of a stopped record, there isn't one when the
+// program exited.
+func stopLocationOf(record gdblib.AsyncResultRecord) (stopLocation, bool) {
+	frame, ok := record.Result["frame"].(map[string]interface{})
+	if !ok {
+		return stopLocation{}, false
+	}
+
+	location := stopLocation{}
+	location.Func, _ = frame["func"].(string)
+	location.File, _ = frame["fullname"].(string)
+	if location.File == "" {
+		location.File, _ = frame["file"].(string)
+	}
+	location.Line, _ = frame["line"].(string)
+	location.Addr, _ = frame["addr"].(string)
+	location.Thread, _ = record.Result["thread-id"].(string)
+	location.Reason, _ = record.Result["reason"].(string)
+	location.Signal, _ = record.Result["signal-name"].(string)
+	location.SignalMeaning, _ = record.Result["signal-meaning"].(string)
+
+	return location, true
+}
+
+// Records where the program stopped. A stop where it last stopped (e.g. a
+// breakpoint in a loop without anything else in it) replaces the last one.
+func (s *stopHistory) record(record gdblib.AsyncResultRecord) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	location, ok := stopLocationOf(record)
+	if !ok {
+		s.current = nil
+		return
+	}
+
+	location.Interrupted = s.interrupting && interruptedStop(record)
+	s.current = &location
+
+	if len(s.locations) > 0 {
+		last := s.locations[len(s.locations)-1]
+		if last.Addr == location.Addr && last.Thread == location.Thread {
+			s.locations[len(s.locations)-1] = location
+			return
+		}
+	}
+
+	if len(s.locations) >= maxStopHistory {
+		s.locations = s.locations[1:]
+	}
+	s.locations = append(s.locations, location)
+}
+
+// Notes that the program (or one of its threads) is running again
+func (s *stopHistory) running(record gdblib.AsyncResultRecord) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	threadId, _ := record.Result["thread-id"].(string)
+	if s.current != nil && (threadId == "all" || threadId == "" || threadId == s.current.Thread) {
+		s.current = nil
+	}
+	s.interrupting = false
+}
+
+// Notes that godbg is about to interrupt the program so that the stop isn't
+// mistaken for the program receiving the signal
+func (s *stopHistory) expectInterrupt() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.interrupting = true
+}
+
+// Returns where the program is stopped now
+func (s *stopHistory) stoppedAt() (stopLocation, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.current == nil {
+		return stopLocation{}, false
+	}
+	return *s.current, true
+}
+
+func (s *stopHistory) get() []stopLocation {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]stopLocation{}, s.locations...)
+}
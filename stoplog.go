@@ -0,0 +1,99 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/sirnewton01/gdblib"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// File that a line is appended to for each stop, nil when there is no stop log
+var stopLogFile *os.File
+
+type stopLogDisplay struct {
+	Number     int
+	Expression string
+	Value      string `json:",omitempty"`
+	Error      string `json:",omitempty"`
+}
+
+type stopLogEntry struct {
+	Time   time.Time
+	Reason string
+	// Missing when the program exited
+	Location *stopLocation   `json:",omitempty"`
+	Frame    *backtraceFrame `json:",omitempty"`
+	Displays []stopLogDisplay
+}
+
+// Looks up the selected frame and the values of the enabled display
+// expressions for the stop. Anything that can't be looked up is left out
+// so that there is still a line in the log for the stop.
+func takeStopLogEntry(mygdb *tracedGDB, record gdblib.AsyncResultRecord) stopLogEntry {
+	entry := stopLogEntry{Time: time.Now(), Displays: []stopLogDisplay{}}
+	entry.Reason, _ = record.Result["reason"].(string)
+
+	location, ok := stopLocationOf(record)
+	if !ok {
+		return entry
+	}
+	entry.Location = &location
+
+	frameResult, err := mygdb.StackInfoFrame()
+	if err == nil {
+		frame := struct {
+			Frame backtraceFrame `json:"frame"`
+		}{}
+
+		if remarshal(frameResult, &frame) == nil {
+			entry.Frame = &frame.Frame
+		}
+	}
+
+	cliOutput, err := cliExec(mygdb, "info display")
+	if err != nil {
+		return entry
+	}
+
+	for _, line := range strings.Split(cliOutput, "\n") {
+		match := displayInfoPattern.FindStringSubmatch(line)
+		if match == nil || match[2] != "y" {
+			continue
+		}
+
+		display := stopLogDisplay{Expression: strings.TrimSpace(match[3])}
+		display.Number, _ = strconv.Atoi(match[1])
+
+		// A display with a format (e.g. "/x count") keeps it
+		command := "output " + display.Expression
+		if strings.HasPrefix(display.Expression, "/") {
+			command = "output" + display.Expression
+		}
+
+		value, err := cliExec(mygdb, command)
+		if err != nil {
+			display.Error = err.Error()
+		} else {
+			display.Value = strings.TrimSpace(value)
+		}
+
+		entry.Displays = append(entry.Displays, display)
+	}
+
+	return entry
+}
+
+func (b *broadcaster) logStop(record gdblib.AsyncResultRecord) {
+	entry := takeStopLogEntry(b.mygdb, record)
+
+	bytes, err := json.Marshal(&entry)
+	if err == nil {
+		stopLogFile.Write(append(bytes, '\n'))
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Matches the name at the end of a member declaration in the ptype output
+// (e.g. "char name[16];" or "unsigned int flag : 3;")
+var memberNamePattern = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\s*(?:\[[^\]]*\])*\s*(:\s*[0-9]+)?;$`)
+
+// Matches a function pointer member (e.g. "int (*callback)(void *);")
+var functionPointerMemberPattern = regexp.MustCompile(`\(\s*\*\s*([A-Za-z_][A-Za-z0-9_]*)\s*\)\s*\(.*\);$`)
+
+var errNotStruct = errors.New("Not a struct, class or union type")
+
+type structMember struct {
+	Name        string
+	Declaration string
+	Bitfield    bool
+}
+
+// A row of the layout table which is either a member, the padding that the
+// compiler put in front of the next member (or at the end) or a gap that a
+// skipped member may be in
+type structLayoutRow struct {
+	Kind        string
+	Name        string `json:",omitempty"`
+	Declaration string `json:",omitempty"`
+	Offset      int
+	Size        int
+	// A skipped member was declared between this member and the one before
+	afterSkipped bool
+}
+
+type structLayout struct {
+	Type    string
+	Size    int
+	Padding int
+	Rows    []structLayoutRow
+	// Members whose offset can't be taken (e.g. bitfields), the gaps that
+	//  they may be in are listed as unknown rather than padding
+	Skipped []string
+}
+
+// Parses the members of the struct out of the ptype output. The members of
+// nested anonymous structs and unions are members of the struct itself so
+// they are listed in their place. Methods and static members are left out.
+func parseStructMembers(cliOutput string) ([]structMember, error) {
+	lines := strings.Split(strings.TrimSpace(cliOutput), "\n")
+	if len(lines) == 0 || !strings.HasSuffix(strings.TrimSpace(lines[0]), "{") {
+		return nil, errNotStruct
+	}
+
+	members := []structMember{}
+	depth := 1
+	declaration := ""
+	block := []string{}
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" || (strings.HasSuffix(line, ":") && !strings.Contains(line, " ")) {
+			// Access labels such as "public:"
+			continue
+		}
+
+		opened := strings.Count(line, "{")
+		closed := strings.Count(line, "}")
+		if depth > 1 || opened > 0 {
+			declaration += line + " "
+			block = append(block, line)
+		}
+		depth += opened - closed
+
+		if depth < 1 {
+			break
+		}
+		if depth > 1 || !strings.HasSuffix(line, ";") {
+			continue
+		}
+
+		if declaration == "" {
+			declaration = line
+		} else {
+			declaration = strings.TrimSpace(declaration)
+		}
+
+		if strings.HasSuffix(strings.TrimSuffix(declaration, ";"), "}") {
+			// An anonymous struct or union ends in "};" without a name
+			nested, err := parseStructMembers(strings.Join(block, "\n"))
+			if err == nil {
+				members = append(members, nested...)
+			}
+		} else if !strings.HasPrefix(declaration, "static ") && !strings.HasPrefix(declaration, "typedef ") {
+			if match := functionPointerMemberPattern.FindStringSubmatch(declaration); match != nil {
+				members = append(members, structMember{match[1], strings.TrimSuffix(declaration, ";"), false})
+			} else if !strings.Contains(declaration, "(") {
+				match := memberNamePattern.FindStringSubmatch(declaration)
+				if match != nil {
+					members = append(members, structMember{match[1], strings.TrimSuffix(declaration, ";"), match[2] != ""})
+				}
+			}
+		}
+
+		declaration = ""
+		block = []string{}
+	}
+
+	return members, nil
+}
+
+type byOffset []structLayoutRow
+
+func (rows byOffset) Len() int           { return len(rows) }
+func (rows byOffset) Swap(i, j int)      { rows[i], rows[j] = rows[j], rows[i] }
+func (rows byOffset) Less(i, j int) bool { return rows[i].Offset < rows[j].Offset }
+
+// Works out where each member of the type is and where the padding between
+// them is from the offsets and sizes that gdb computes.
+func layoutStruct(mygdb *tracedGDB, typeName string) (*structLayout, error) {
+	cliOutput, err := cliExec(mygdb, "ptype "+typeName)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := parseStructMembers(strings.TrimPrefix(strings.TrimSpace(cliOutput), "type = "))
+	if err != nil {
+		return nil, err
+	}
+
+	layout := &structLayout{Type: typeName, Rows: []structLayoutRow{}, Skipped: []string{}}
+
+	layout.Size, err = evaluateInt(mygdb, "sizeof("+typeName+")")
+	if err != nil {
+		return nil, err
+	}
+
+	pointer := "((" + typeName + " *)0)->"
+	fields := []structLayoutRow{}
+	skipped := false
+	for _, member := range members {
+		if member.Bitfield {
+			layout.Skipped = append(layout.Skipped, member.Name)
+			skipped = true
+			continue
+		}
+
+		offset, err := evaluateInt(mygdb, "(unsigned long)&"+pointer+member.Name)
+		if err != nil {
+			layout.Skipped = append(layout.Skipped, member.Name)
+			skipped = true
+			continue
+		}
+
+		size, err := evaluateInt(mygdb, "sizeof("+pointer+member.Name+")")
+		if err != nil {
+			layout.Skipped = append(layout.Skipped, member.Name)
+			skipped = true
+			continue
+		}
+
+		fields = append(fields, structLayoutRow{"member", member.Name, member.Declaration, offset, size, skipped})
+		skipped = false
+	}
+
+	// The space that a skipped member was declared in isn't known to be
+	//  padding.
+	gap := func(offset int, size int, afterSkipped bool) {
+		if afterSkipped {
+			layout.Rows = append(layout.Rows, structLayoutRow{Kind: "unknown", Offset: offset, Size: size})
+		} else {
+			layout.Rows = append(layout.Rows, structLayoutRow{Kind: "padding", Offset: offset, Size: size})
+			layout.Padding += size
+		}
+	}
+
+	// Members of a union all start at the beginning so the end is the
+	//  furthest that any member so far reaches.
+	sort.Stable(byOffset(fields))
+
+	end := 0
+	for _, field := range fields {
+		if field.Offset > end {
+			gap(end, field.Offset-end, field.afterSkipped)
+		}
+		layout.Rows = append(layout.Rows, field)
+
+		if field.Offset+field.Size > end {
+			end = field.Offset + field.Size
+		}
+	}
+
+	if layout.Size > end {
+		gap(end, layout.Size-end, skipped)
+	}
+
+	return layout, nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2013 Chris McGee <sirnewton_01@yahoo.ca>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// Maximum number of entries kept in memory for the session
+	maxTranscriptEntries = 10000
+	// Responses larger than this (e.g. whole source files) are truncated
+	maxTranscriptResponse = 4096
+)
+
+// Either a request that was handled or one of the MI commands that was sent
+// to gdb for it
+type transcriptEntry struct {
+	Time     time.Time
+	Handler  string     `json:",omitempty"`
+	Request  string     `json:",omitempty"`
+	Status   int        `json:",omitempty"`
+	Response string     `json:",omitempty"`
+	Command  *miCommand `json:",omitempty"`
+}
+
+type transcript struct {
+	mutex   sync.Mutex
+	entries []transcriptEntry
+	file    *os.File
+}
+
+var sessionTranscript transcript
+
+func (t *transcript) record(entry transcriptEntry) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if len(t.entries) >= maxTranscriptEntries {
+		t.entries = t.entries[1:]
+	}
+	t.entries = append(t.entries, entry)
+
+	if t.file != nil {
+		bytes, err := json.Marshal(&entry)
+		if err == nil {
+			t.file.Write(append(bytes, '\n'))
+		}
+	}
+}
+
+func (t *transcript) recordCommand(cmd miCommand) {
+	t.record(transcriptEntry{Time: cmd.Time, Command: &cmd})
+}
+
+func (t *transcript) list() []transcriptEntry {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return append([]transcriptEntry{}, t.entries...)
+}
+
+func (t *transcript) clear() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.entries = nil
+}
+
+// Captures the status and body of a response as it is written
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = 200
+	}
+	if w.body.Len() < maxTranscriptResponse {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func wrapTranscript(path string, delegate handlerFunc) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Requests that are denied don't get to gdb so they aren't part of
+		//  the transcript.
+		if !authorized(r) {
+			delegate(w, r)
+			return
+		}
+
+		// The request body is read up front so that it can be recorded and
+		//  then handed to the real handler.
+		requestBytes, _ := ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(bytes.NewReader(requestBytes))
+
+		recorder := &recordingResponseWriter{ResponseWriter: w}
+		delegate(recorder, r)
+
+		response := recorder.body.String()
+		if len(response) > maxTranscriptResponse {
+			response = response[:maxTranscriptResponse] + "..."
+		}
+
+		status := recorder.status
+		if status == 0 {
+			status = 200
+		}
+
+		sessionTranscript.record(transcriptEntry{Time: time.Now(), Handler: path,
+			Request: string(requestBytes), Status: status, Response: response})
+	}
+}